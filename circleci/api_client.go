@@ -2,49 +2,57 @@ package circleci
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
-	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"golang.org/x/sync/singleflight"
 )
 
 const (
 	queryLimit = 100 // maximum that CircleCI allows
+
+	tokenHeader     = "Circle-Token"
+	tokenQueryParam = "circle-token"
+	redactedValue   = "REDACTED"
 )
 
 var (
 	defaultBaseURL = &url.URL{Host: "circleci.com", Scheme: "https", Path: "/api/v1.1/"}
-	defaultLogger  = log.New(os.Stderr, "", log.LstdFlags)
 )
 
-// Logger is a minimal interface for injecting custom logging logic for debug logs
-type Logger interface {
-	Printf(fmt string, args ...interface{})
-}
-
-// APIError represents an error from CircleCI
-type APIError struct {
-	HTTPStatusCode int
-	Message        string
-}
-
-func (e *APIError) Error() string {
-	return fmt.Sprintf("%d: %s", e.HTTPStatusCode, e.Message)
-}
-
 type ApiClient struct {
-	BaseURL    *url.URL     // CircleCI API endpoint (defaults to DefaultEndpoint)
-	Token      string       // CircleCI API token (needed for private repositories and mutative actions)
-	HTTPClient *http.Client // HTTPClient to use for connecting to CircleCI (defaults to http.DefaultClient)
-
-	Debug  bool   // debug logging enabled
-	Logger Logger // logger to send debug messages on (if enabled), defaults to logging to stderr with the standard flags
+	BaseURL       *url.URL     // CircleCI API endpoint (defaults to DefaultEndpoint)
+	V2BaseURL     *url.URL     // CircleCI API v2 endpoint (defaults to defaultV2BaseURL)
+	RunnerBaseURL *url.URL     // CircleCI runner API endpoint
+	Server        bool         // talking to a self-hosted CircleCI server install rather than circleci.com
+	Token         string       // CircleCI API token (needed for private repositories and mutative actions)
+	TokenInQuery  bool         // send the token as the circle-token query parameter instead of the Circle-Token header (older CircleCI server installs)
+	HTTPClient    *http.Client // HTTPClient to use for connecting to CircleCI (defaults to http.DefaultClient)
+	CurrentUser   *User        // user the token belongs to, set once the token was validated
+
+	MaxRetries   int           // number of times a failed request is retried (defaults to no retries)
+	MinRetryWait time.Duration // wait before the first retry, doubled on every further retry (defaults to 1s)
+	MaxRetryWait time.Duration // upper bound for the wait between retries (defaults to 30s)
+
+	MaxRequestsPerSecond  float64 // rate limit shared by all requests of this client (0 disables it)
+	MaxConcurrentRequests int     // number of requests in flight at once (0 means unlimited)
+
+	limiterOnce sync.Once
+	limiter     *rateLimiter
+
+	flights            singleflight.Group
+	projectsMu         sync.Mutex
+	projects           []*Project // memoized result of ListProjects
+	projectsGeneration int        // bumped whenever the memoized projects are dropped
 }
 
 func (c *ApiClient) baseURL() *url.URL {
@@ -63,45 +71,42 @@ func (c *ApiClient) client() *http.Client {
 	return c.HTTPClient
 }
 
-func (c *ApiClient) logger() Logger {
-	if c.Logger == nil {
-		return defaultLogger
-	}
+func (c *ApiClient) rateLimiter() *rateLimiter {
+	c.limiterOnce.Do(func() {
+		c.limiter = newRateLimiter(c.MaxRequestsPerSecond, c.MaxConcurrentRequests)
+	})
 
-	return c.Logger
+	return c.limiter
 }
 
-func (c *ApiClient) debug(format string, args ...interface{}) {
-	if c.Debug {
-		c.logger().Printf(format, args...)
+// redact replaces every occurrence of the API token in s, so the token never ends
+// up in a log line or an error message
+func (c *ApiClient) redact(s string) string {
+	if c.Token == "" {
+		return s
 	}
-}
 
-func (c *ApiClient) debugRequest(req *http.Request) {
-	if c.Debug {
-		out, err := httputil.DumpRequestOut(req, true)
-		if err != nil {
-			c.debug("error debugging request %+v: %s", req, err)
-		}
-		c.debug("request:\n%+v", string(out))
-	}
+	s = strings.ReplaceAll(s, c.Token, redactedValue)
+	return strings.ReplaceAll(s, url.QueryEscape(c.Token), redactedValue)
 }
 
-func (c *ApiClient) debugResponse(resp *http.Response) {
-	if c.Debug {
-		out, err := httputil.DumpResponse(resp, true)
-		if err != nil {
-			c.debug("error debugging response %+v: %s", resp, err)
-		}
-		c.debug("response:\n%+v", string(out))
+// redactError strips the API token from errors returned by the HTTP client, which
+// embed the full request URL
+func (c *ApiClient) redactError(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		return &url.Error{Op: urlErr.Op, URL: c.redact(urlErr.URL), Err: urlErr.Err}
 	}
+
+	return err
 }
 
 // FollowProject follows a project
-func (c *ApiClient) FollowProject(vcstype, account, reponame string) (*Project, error) {
+func (c *ApiClient) FollowProject(ctx context.Context, vcstype, account, reponame string) (*Project, error) {
 	response := &Project{}
 
-	err := c.request("POST", fmt.Sprintf("project/%s/%s/%s/follow", vcstype, account, reponame), response, nil, nil)
+	// following an already followed project is a no-op, so this is safe to retry
+	err := c.request(withRetrySafe(ctx), "POST", fmt.Sprintf("project/%s/%s/%s/follow", vcstype, account, reponame), response, nil, nil)
+	c.forgetProjects()
 	if err != nil {
 		return nil, err
 	}
@@ -110,45 +115,97 @@ func (c *ApiClient) FollowProject(vcstype, account, reponame string) (*Project,
 }
 
 // ListProjects returns the list of projects the user is watching
-func (c *ApiClient) ListProjects() ([]*Project, error) {
-	projects := []*Project{}
+// The list is fetched once and shared by every caller until a project is followed
+// or disabled; concurrent callers share a single in-flight request.
+func (c *ApiClient) ListProjects(ctx context.Context) ([]*Project, error) {
+	c.projectsMu.Lock()
+	projects, generation := c.projects, c.projectsGeneration
+	c.projectsMu.Unlock()
+
+	if projects != nil {
+		return projects, nil
+	}
+
+	v, err, _ := c.flights.Do("projects", func() (interface{}, error) {
+		projects := []*Project{}
 
-	err := c.request("GET", "projects", &projects, nil, nil)
+		err := c.request(ctx, "GET", "projects", &projects, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		c.projectsMu.Lock()
+		if c.projectsGeneration == generation {
+			c.projects = projects
+		}
+		c.projectsMu.Unlock()
+
+		return projects, nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return projects, nil
+	return v.([]*Project), nil
+}
+
+// projectsMemoized reports whether the list of watched projects is memoized
+func (c *ApiClient) projectsMemoized() bool {
+	c.projectsMu.Lock()
+	defer c.projectsMu.Unlock()
+
+	return c.projects != nil
 }
 
-// GetProject retrieves a specific project
-// Returns nil of the project is not in the list of watched projects
-func (c *ApiClient) GetProject(vcstype, account, reponame string) (*Project, error) {
-	projects, err := c.ListProjects()
+// forgetProjects drops the memoized list of watched projects
+func (c *ApiClient) forgetProjects() {
+	c.projectsMu.Lock()
+	defer c.projectsMu.Unlock()
+
+	c.projects = nil
+	c.projectsGeneration++
+}
+
+// GetProject retrieves a specific project. Projects are looked up in the
+// memoized list of followed projects; until it is loaded, the API v2
+// single-project endpoint is asked first, so a missing project costs a single
+// request. That endpoint also returns disabled projects, so a project that isn't
+// in the list is reported as ErrNotFound.
+func (c *ApiClient) GetProject(ctx context.Context, vcstype, account, reponame string) (*Project, error) {
+	if !c.projectsMemoized() {
+		if _, err := c.V2().GetProject(ctx, ProjectSlug(vcstype, account, reponame)); err != nil {
+			return nil, err
+		}
+	}
+
+	projects, err := c.ListProjects(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, project := range projects {
-		if vcstype == project.VcsType && account == project.Username && reponame == project.Reponame {
-			return project, nil
+	for _, p := range projects {
+		if p.VcsType == vcstype && strings.EqualFold(p.Username, account) && strings.EqualFold(p.Reponame, reponame) {
+			project := *p
+			return &project, nil
 		}
 	}
 
-	return nil, errors.New(fmt.Sprintf("Unable to find project %s/%s/%s", vcstype, account, reponame))
+	return nil, fmt.Errorf("project %s is not followed: %w", ProjectSlug(vcstype, account, reponame), ErrNotFound)
 }
 
 // DisableProject disables a project
-func (c *ApiClient) DisableProject(vcstype, account, reponame string) error {
-	return c.request("DELETE", fmt.Sprintf("project/%s/%s/%s/enable", vcstype, account, reponame), nil, nil, nil)
+func (c *ApiClient) DisableProject(ctx context.Context, vcstype, account, reponame string) error {
+	defer c.forgetProjects()
+
+	return c.request(ctx, "DELETE", fmt.Sprintf("project/%s/%s/%s/enable", vcstype, account, reponame), nil, nil, nil)
 }
 
 // ListEnvVars list environment variable to the specified project
 // Returns the env vars (the value will be masked)
-func (c *ApiClient) ListEnvVars(vcstype, account, reponame string) ([]EnvVar, error) {
+func (c *ApiClient) ListEnvVars(ctx context.Context, vcstype, account, reponame string) ([]EnvVar, error) {
 	envVar := []EnvVar{}
 
-	err := c.request("GET", fmt.Sprintf("project/%s/%s/%s/envvar", vcstype, account, reponame), &envVar, nil, nil)
+	err := c.request(ctx, "GET", fmt.Sprintf("project/%s/%s/%s/envvar", vcstype, account, reponame), &envVar, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -158,10 +215,11 @@ func (c *ApiClient) ListEnvVars(vcstype, account, reponame string) ([]EnvVar, er
 
 // AddEnvVar adds a new environment variable to the specified project
 // Returns the added env var (the value will be masked)
-func (c *ApiClient) AddEnvVar(vcstype, account, reponame, name, value string) (*EnvVar, error) {
+func (c *ApiClient) AddEnvVar(ctx context.Context, vcstype, account, reponame, name, value string) (*EnvVar, error) {
 	envVar := &EnvVar{}
 
-	err := c.request("POST", fmt.Sprintf("project/%s/%s/%s/envvar", vcstype, account, reponame), envVar, nil, &EnvVar{Name: name, Value: value})
+	// adding an existing variable overwrites its value, so this is safe to retry
+	err := c.request(withRetrySafe(ctx), "POST", fmt.Sprintf("project/%s/%s/%s/envvar", vcstype, account, reponame), envVar, nil, &EnvVar{Name: name, Value: value})
 	if err != nil {
 		return nil, err
 	}
@@ -170,8 +228,50 @@ func (c *ApiClient) AddEnvVar(vcstype, account, reponame, name, value string) (*
 }
 
 // DeleteEnvVar deletes the specified environment variable from the project
-func (c *ApiClient) DeleteEnvVar(vcstype, account, reponame, name string) error {
-	return c.request("DELETE", fmt.Sprintf("project/%s/%s/%s/envvar/%s", vcstype, account, reponame, name), nil, nil, nil)
+func (c *ApiClient) DeleteEnvVar(ctx context.Context, vcstype, account, reponame, name string) error {
+	return c.request(ctx, "DELETE", fmt.Sprintf("project/%s/%s/%s/envvar/%s", vcstype, account, reponame, name), nil, nil, nil)
+}
+
+// send performs a single attempt of an API request
+func (c *ApiClient) send(ctx context.Context, method string, u *url.URL, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Body = nopCloser{bytes.NewBuffer(body)}
+		req.ContentLength = int64(len(body))
+	}
+
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Content-Type", "application/json")
+	if !c.TokenInQuery {
+		req.Header.Set(tokenHeader, c.Token)
+	}
+
+	release, waited, err := c.rateLimiter().acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if waited >= time.Millisecond {
+		tflog.SubsystemDebug(ctx, logSubsystem, "waited for the client-side rate limiter", map[string]interface{}{"wait": waited.String()})
+	}
+
+	c.logRequest(ctx, req, body)
+
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// the response body is read here, so the concurrency slot is held until the
+	// whole response has been received
+	c.logResponse(ctx, resp)
+
+	return resp, nil
 }
 
 type nopCloser struct {
@@ -180,64 +280,71 @@ type nopCloser struct {
 
 func (n nopCloser) Close() error { return nil }
 
-func (c *ApiClient) request(method, path string, responseStruct interface{}, params url.Values, bodyStruct interface{}) error {
+func (c *ApiClient) request(ctx context.Context, method, path string, responseStruct interface{}, params url.Values, bodyStruct interface{}) error {
+	return c.do(ctx, c.baseURL(), method, path, responseStruct, params, bodyStruct)
+}
+
+// do sends a request to path relative to base, retrying it if needed, and decodes
+// the JSON response into responseStruct
+func (c *ApiClient) do(ctx context.Context, base *url.URL, method, path string, responseStruct interface{}, params url.Values, bodyStruct interface{}) error {
 	if params == nil {
 		params = url.Values{}
 	}
-	params.Set("circle-token", c.Token)
-
-	u := c.baseURL().ResolveReference(&url.URL{Path: path, RawQuery: params.Encode()})
-
-	c.debug("building request for %s", u)
-
-	req, err := http.NewRequest(method, u.String(), nil)
-	if err != nil {
-		return err
+	if c.TokenInQuery {
+		params.Set(tokenQueryParam, c.Token)
 	}
 
+	u := base.ResolveReference(&url.URL{Path: path, RawQuery: params.Encode()})
+
+	var body []byte
 	if bodyStruct != nil {
-		b, err := json.Marshal(bodyStruct)
+		var err error
+		body, err = json.Marshal(bodyStruct)
 		if err != nil {
 			return err
 		}
-
-		req.Body = nopCloser{bytes.NewBuffer(b)}
 	}
 
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Content-Type", "application/json")
+	ctx = newLogContext(ctx)
+	retrySafe := isIdempotent(method) || isRetrySafe(ctx)
 
-	c.debugRequest(req)
+	var resp *http.Response
+	var err error
+	for attempt := 0; ; attempt++ {
+		resp, err = c.send(ctx, method, u, body)
 
-	resp, err := c.client().Do(req)
+		wait, retry := c.retryWait(attempt, retrySafe, resp, err)
+		if !retry {
+			break
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		tflog.SubsystemWarn(ctx, logSubsystem, "retrying CircleCI API request", map[string]interface{}{
+			"method":  method,
+			"url":     c.redactURL(u),
+			"attempt": attempt + 1,
+			"wait":    wait.String(),
+		})
+
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
 	if err != nil {
-		return err
+		return c.redactError(err)
 	}
 	defer resp.Body.Close()
 
-	c.debugResponse(resp)
-
 	if resp.StatusCode >= 300 {
 		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			return &APIError{HTTPStatusCode: resp.StatusCode, Message: "unable to parse response: %s"}
-		}
-
-		if len(body) > 0 {
-			message := struct {
-				Message string `json:"message"`
-			}{}
-			err = json.Unmarshal(body, &message)
-			if err != nil {
-				return &APIError{
-					HTTPStatusCode: resp.StatusCode,
-					Message:        fmt.Sprintf("unable to parse API response: %s", err),
-				}
-			}
-			return &APIError{HTTPStatusCode: resp.StatusCode, Message: message.Message}
+			return &APIError{HTTPStatusCode: resp.StatusCode, Message: fmt.Sprintf("unable to read response: %s", err)}
 		}
 
-		return &APIError{HTTPStatusCode: resp.StatusCode}
+		return newAPIError(resp.StatusCode, body)
 	}
 
 	if responseStruct != nil {
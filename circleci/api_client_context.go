@@ -0,0 +1,213 @@
+package circleci
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// ContextOwner identifies the organization or account a context belongs to,
+// either by id or by slug, e.g. gh/org
+type ContextOwner struct {
+	ID   string `json:"id,omitempty"`
+	Slug string `json:"slug,omitempty"`
+	Type string `json:"type,omitempty"`
+}
+
+// Context represents a CircleCI context
+type Context struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateContext creates a context owned by owner
+func (c *V2Client) CreateContext(ctx context.Context, name string, owner ContextOwner) (*Context, error) {
+	result := &Context{}
+
+	body := struct {
+		Name  string       `json:"name"`
+		Owner ContextOwner `json:"owner"`
+	}{name, owner}
+
+	err := c.request(ctx, "POST", "context", result, nil, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetContext retrieves a context by its id
+func (c *V2Client) GetContext(ctx context.Context, id string) (*Context, error) {
+	result := &Context{}
+
+	err := c.request(ctx, "GET", fmt.Sprintf("context/%s", id), result, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ListContexts lists the contexts of owner
+func (c *V2Client) ListContexts(ctx context.Context, owner ContextOwner) ([]Context, error) {
+	params := url.Values{}
+	if owner.ID != "" {
+		params.Set("owner-id", owner.ID)
+	}
+	if owner.Slug != "" {
+		params.Set("owner-slug", owner.Slug)
+	}
+	if owner.Type != "" {
+		params.Set("owner-type", owner.Type)
+	}
+
+	contexts := []Context{}
+
+	it := c.pages("context", params)
+	for {
+		var page []Context
+
+		more, err := it.Next(ctx, &page)
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			break
+		}
+
+		contexts = append(contexts, page...)
+	}
+
+	return contexts, nil
+}
+
+// FindContext looks a context of owner up by name
+func (c *V2Client) FindContext(ctx context.Context, owner ContextOwner, name string) (*Context, error) {
+	contexts, err := c.ListContexts(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range contexts {
+		if contexts[i].Name == name {
+			return &contexts[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("context %q: %w", name, ErrNotFound)
+}
+
+// DeleteContext deletes a context along with its environment variables
+func (c *V2Client) DeleteContext(ctx context.Context, id string) error {
+	return c.request(ctx, "DELETE", fmt.Sprintf("context/%s", id), nil, nil, nil)
+}
+
+// ContextEnvVar represents an environment variable of a context. CircleCI
+// never returns its value, not even masked.
+type ContextEnvVar struct {
+	Variable  string    `json:"variable"`
+	ContextID string    `json:"context_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ListContextEnvVars lists the environment variables of a context
+func (c *V2Client) ListContextEnvVars(ctx context.Context, contextID string) ([]ContextEnvVar, error) {
+	envVars := []ContextEnvVar{}
+
+	it := c.pages(fmt.Sprintf("context/%s/environment-variable", contextID), nil)
+	for {
+		var page []ContextEnvVar
+
+		more, err := it.Next(ctx, &page)
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			break
+		}
+
+		envVars = append(envVars, page...)
+	}
+
+	return envVars, nil
+}
+
+// SetContextEnvVar adds or replaces an environment variable of a context
+func (c *V2Client) SetContextEnvVar(ctx context.Context, contextID, name, value string) (*ContextEnvVar, error) {
+	envVar := &ContextEnvVar{}
+
+	body := struct {
+		Value string `json:"value"`
+	}{value}
+
+	err := c.request(ctx, "PUT", fmt.Sprintf("context/%s/environment-variable/%s", contextID, name), envVar, nil, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return envVar, nil
+}
+
+// DeleteContextEnvVar deletes an environment variable of a context
+func (c *V2Client) DeleteContextEnvVar(ctx context.Context, contextID, name string) error {
+	return c.request(ctx, "DELETE", fmt.Sprintf("context/%s/environment-variable/%s", contextID, name), nil, nil, nil)
+}
+
+// ContextRestriction limits which pipelines can use a context, by project,
+// security group or expression
+type ContextRestriction struct {
+	ID               string `json:"id"`
+	ContextID        string `json:"context_id"`
+	ProjectID        string `json:"project_id,omitempty"`
+	Name             string `json:"name,omitempty"`
+	RestrictionType  string `json:"restriction_type"`
+	RestrictionValue string `json:"restriction_value"`
+}
+
+// ListContextRestrictions lists the restrictions of a context
+func (c *V2Client) ListContextRestrictions(ctx context.Context, contextID string) ([]ContextRestriction, error) {
+	restrictions := []ContextRestriction{}
+
+	it := c.pages(fmt.Sprintf("context/%s/restrictions", contextID), nil)
+	for {
+		var page []ContextRestriction
+
+		more, err := it.Next(ctx, &page)
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			break
+		}
+
+		restrictions = append(restrictions, page...)
+	}
+
+	return restrictions, nil
+}
+
+// CreateContextRestriction adds a restriction to a context
+func (c *V2Client) CreateContextRestriction(ctx context.Context, contextID, restrictionType, value string) (*ContextRestriction, error) {
+	restriction := &ContextRestriction{}
+
+	body := struct {
+		RestrictionType  string `json:"restriction_type"`
+		RestrictionValue string `json:"restriction_value"`
+	}{restrictionType, value}
+
+	err := c.request(ctx, "POST", fmt.Sprintf("context/%s/restrictions", contextID), restriction, nil, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return restriction, nil
+}
+
+// DeleteContextRestriction deletes a restriction of a context
+func (c *V2Client) DeleteContextRestriction(ctx context.Context, contextID, id string) error {
+	return c.request(ctx, "DELETE", fmt.Sprintf("context/%s/restrictions/%s", contextID, id), nil, nil, nil)
+}
@@ -0,0 +1,186 @@
+package circleci
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestApiClientTokenAuth(t *testing.T) {
+	cases := []struct {
+		name         string
+		tokenInQuery bool
+		wantHeader   string
+		wantQuery    string
+	}{
+		{
+			name:       "header",
+			wantHeader: "secret-token",
+		},
+		{
+			name:         "query",
+			tokenInQuery: true,
+			wantQuery:    "secret-token",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotHeader, gotQuery string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotHeader = r.Header.Get(tokenHeader)
+				gotQuery = r.URL.Query().Get(tokenQueryParam)
+				w.Write([]byte(`[]`))
+			}))
+			defer server.Close()
+
+			baseURL, _ := url.Parse(server.URL + "/api/v1.1/")
+			client := &ApiClient{BaseURL: baseURL, Token: "secret-token", TokenInQuery: tc.tokenInQuery}
+
+			if _, err := client.ListProjects(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if gotHeader != tc.wantHeader {
+				t.Errorf("Circle-Token header was incorrect, got: %q, want: %q.", gotHeader, tc.wantHeader)
+			}
+
+			if gotQuery != tc.wantQuery {
+				t.Errorf("circle-token query parameter was incorrect, got: %q, want: %q.", gotQuery, tc.wantQuery)
+			}
+		})
+	}
+}
+
+func TestApiClientErrorRedactsToken(t *testing.T) {
+	baseURL, _ := url.Parse("http://127.0.0.1:0/api/v1.1/")
+	client := &ApiClient{BaseURL: baseURL, Token: "secret-token", TokenInQuery: true}
+
+	_, err := client.ListProjects(context.Background())
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("error contains the API token: %s", err)
+	}
+}
+
+func TestApiClientListProjectsMemoized(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && r.URL.Path == "/api/v1.1/projects" {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+
+			<-release
+			w.Write([]byte(`[{"username":"org","reponame":"repo","vcs_type":"github"}]`))
+			return
+		}
+		w.Write([]byte(`{"username":"org","reponame":"other","vcs_type":"github"}`))
+	}))
+	defer server.Close()
+
+	baseURL, _ := url.Parse(server.URL + "/api/v1.1/")
+	client := &ApiClient{BaseURL: baseURL}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, err := client.ListProjects(context.Background()); err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if _, err := client.ListProjects(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("Number of ListProjects requests was incorrect, got: %d, want: 1.", calls)
+	}
+
+	if _, err := client.FollowProject(context.Background(), "github", "org", "other"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := client.ListProjects(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if calls != 2 {
+		t.Errorf("Following a project did not refresh the project list, got %d requests, want: 2.", calls)
+	}
+}
+
+func TestApiClientGetProject(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+
+		switch r.URL.Path {
+		case "/api/v2/project/gh/org/repo", "/api/v2/project/gh/org/disabled":
+			w.Write([]byte(`{"slug":"` + strings.TrimPrefix(r.URL.Path, "/api/v2/project/") + `","name":"repo","organization_name":"Org"}`))
+		case "/api/v1.1/projects":
+			w.Write([]byte(`[{"username":"Org","reponame":"repo","vcs_type":"github"}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	baseURL, _ := url.Parse(server.URL + "/api/v1.1/")
+	v2BaseURL, _ := url.Parse(server.URL + "/api/v2/")
+	client := &ApiClient{BaseURL: baseURL, V2BaseURL: v2BaseURL}
+
+	// a missing project is found out without listing the projects
+	if _, err := client.GetProject(context.Background(), "github", "org", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a missing project to be not found, got: %v.", err)
+	}
+	if len(requests) != 1 {
+		t.Errorf("Requests were incorrect, got: %v.", requests)
+	}
+
+	project, err := client.GetProject(context.Background(), "github", "org", "repo")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := Project{Username: "Org", Reponame: "repo", VcsType: "github"}
+	if *project != expected {
+		t.Errorf("Project was incorrect, got: %+v, want: %+v.", *project, expected)
+	}
+
+	// disabled projects are still returned by the v2 endpoint, but not followed
+	requests = nil
+	if _, err := client.GetProject(context.Background(), "github", "org", "disabled"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a project that isn't followed to be not found, got: %v.", err)
+	}
+
+	// once the projects are memoized, lookups don't make requests
+	if _, err := client.GetProject(context.Background(), "github", "ORG", "repo"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if len(requests) != 0 {
+		t.Errorf("Expected memoized lookups, got requests: %v.", requests)
+	}
+}
@@ -0,0 +1,295 @@
+package circleci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	defaultV2BaseURL = &url.URL{Host: "circleci.com", Scheme: "https", Path: "/api/v2/"}
+)
+
+// V2Client is a client for the CircleCI API v2. It shares authentication,
+// logging, retries and rate limiting with the ApiClient it was created from.
+type V2Client struct {
+	client *ApiClient
+}
+
+// V2 returns a client for the CircleCI API v2
+func (c *ApiClient) V2() *V2Client {
+	return &V2Client{client: c}
+}
+
+func (c *ApiClient) v2BaseURL() *url.URL {
+	if c.V2BaseURL == nil {
+		return defaultV2BaseURL
+	}
+
+	return c.V2BaseURL
+}
+
+func (c *V2Client) request(ctx context.Context, method, path string, responseStruct interface{}, params url.Values, bodyStruct interface{}) error {
+	return c.client.do(ctx, c.client.v2BaseURL(), method, path, responseStruct, params, bodyStruct)
+}
+
+// ProjectSlug builds the v2 project slug, e.g. gh/org/repo, for a project
+// identified the v1.1 way
+func ProjectSlug(vcstype, account, reponame string) string {
+	switch vcstype {
+	case "github":
+		vcstype = "gh"
+	case "bitbucket":
+		vcstype = "bb"
+	}
+
+	return fmt.Sprintf("%s/%s/%s", vcstype, account, reponame)
+}
+
+// parseProjectSlug splits a project slug such as gh/org/repo, bb/org/repo or
+// circleci/orgid/projectid into its vcs type, organization and project parts.
+// Long vcs names (github, bitbucket) are accepted and shortened.
+func parseProjectSlug(slug string) (string, string, string, error) {
+	parts := strings.Split(slug, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", "", fmt.Errorf("invalid project slug %q, expected <vcs>/<organization>/<project>", slug)
+	}
+
+	switch parts[0] {
+	case "gh", "github":
+		parts[0] = "gh"
+	case "bb", "bitbucket":
+		parts[0] = "bb"
+	case "circleci":
+	default:
+		return "", "", "", fmt.Errorf("invalid project slug %q, unknown vcs type %q", slug, parts[0])
+	}
+
+	return parts[0], parts[1], parts[2], nil
+}
+
+// projectPath returns the API path of a project slug
+func projectPath(slug string) (string, error) {
+	vcs, org, project, err := parseProjectSlug(slug)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("project/%s/%s/%s", vcs, org, project), nil
+}
+
+// pageIterator follows next_page_token through the pages of a v2 listing
+type pageIterator struct {
+	client *V2Client
+	path   string
+	params url.Values
+
+	nextPageToken string
+	done          bool
+}
+
+func (c *V2Client) pages(path string, params url.Values) *pageIterator {
+	if params == nil {
+		params = url.Values{}
+	}
+
+	return &pageIterator{client: c, path: path, params: params}
+}
+
+// Next fetches the next page and decodes its items into items, which must be a
+// pointer to a slice. It returns false once every page has been read.
+func (it *pageIterator) Next(ctx context.Context, items interface{}) (bool, error) {
+	if it.done {
+		return false, nil
+	}
+
+	params := url.Values{}
+	for k, v := range it.params {
+		params[k] = v
+	}
+	if it.nextPageToken != "" {
+		params.Set("page-token", it.nextPageToken)
+	}
+
+	page := struct {
+		Items         json.RawMessage `json:"items"`
+		NextPageToken string          `json:"next_page_token"`
+	}{}
+
+	if err := it.client.request(ctx, "GET", it.path, &page, params, nil); err != nil {
+		return false, err
+	}
+
+	it.nextPageToken = page.NextPageToken
+	it.done = page.NextPageToken == ""
+
+	if len(page.Items) == 0 || string(page.Items) == "null" {
+		return true, nil
+	}
+
+	if err := json.Unmarshal(page.Items, items); err != nil {
+		return false, fmt.Errorf("unable to parse page of %s: %s", it.path, err)
+	}
+
+	return true, nil
+}
+
+// GetProject retrieves a project by its slug
+func (c *V2Client) GetProject(ctx context.Context, slug string) (*ProjectV2, error) {
+	path, err := projectPath(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	project := &ProjectV2{}
+
+	err = c.request(ctx, "GET", path, project, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return project, nil
+}
+
+// ListEnvVars lists the environment variables of a project
+// Returns the env vars (the value will be masked)
+func (c *V2Client) ListEnvVars(ctx context.Context, slug string) ([]EnvVarV2, error) {
+	path, err := projectPath(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	envVars := []EnvVarV2{}
+
+	it := c.pages(path+"/envvar", nil)
+	for {
+		var page []EnvVarV2
+
+		more, err := it.Next(ctx, &page)
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			break
+		}
+
+		envVars = append(envVars, page...)
+	}
+
+	return envVars, nil
+}
+
+// GetEnvVar retrieves a single environment variable of a project
+// Returns the env var (the value will be masked)
+func (c *V2Client) GetEnvVar(ctx context.Context, slug, name string) (*EnvVarV2, error) {
+	path, err := projectPath(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	envVar := &EnvVarV2{}
+
+	err = c.request(ctx, "GET", fmt.Sprintf("%s/envvar/%s", path, name), envVar, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return envVar, nil
+}
+
+// AddEnvVar adds or replaces an environment variable of a project
+// Returns the added env var (the value will be masked)
+func (c *V2Client) AddEnvVar(ctx context.Context, slug, name, value string) (*EnvVarV2, error) {
+	path, err := projectPath(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	envVar := &EnvVarV2{}
+
+	// adding an existing variable overwrites its value, so this is safe to retry
+	err = c.request(withRetrySafe(ctx), "POST", path+"/envvar", envVar, nil, &EnvVarV2{Name: name, Value: value})
+	if err != nil {
+		return nil, err
+	}
+
+	return envVar, nil
+}
+
+// DeleteEnvVar deletes an environment variable of a project
+func (c *V2Client) DeleteEnvVar(ctx context.Context, slug, name string) error {
+	path, err := projectPath(slug)
+	if err != nil {
+		return err
+	}
+
+	return c.request(ctx, "DELETE", fmt.Sprintf("%s/envvar/%s", path, name), nil, nil, nil)
+}
+
+// Me retrieves the user the API token belongs to
+func (c *V2Client) Me(ctx context.Context) (*User, error) {
+	user := &User{}
+
+	err := c.request(ctx, "GET", "me", user, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// ListCollaborations lists the organizations the user is a member of
+func (c *V2Client) ListCollaborations(ctx context.Context) ([]Collaboration, error) {
+	collaborations := []Collaboration{}
+
+	err := c.request(ctx, "GET", "me/collaborations", &collaborations, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return collaborations, nil
+}
+
+// ProjectV2 represents a project as returned by the API v2
+type ProjectV2 struct {
+	ID               string  `json:"id"`
+	Slug             string  `json:"slug"`
+	Name             string  `json:"name"`
+	OrganizationName string  `json:"organization_name"`
+	OrganizationSlug string  `json:"organization_slug"`
+	OrganizationID   string  `json:"organization_id"`
+	VcsInfo          VcsInfo `json:"vcs_info"`
+}
+
+// VcsInfo describes the repository a project is built from
+type VcsInfo struct {
+	VcsURL        string `json:"vcs_url"`
+	Provider      string `json:"provider"`
+	DefaultBranch string `json:"default_branch"`
+}
+
+// EnvVarV2 represents a project environment variable as returned by the API v2
+type EnvVarV2 struct {
+	Name      string     `json:"name"`
+	Value     string     `json:"value"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// User represents a CircleCI user
+type User struct {
+	ID    string `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+// Collaboration represents an organization the user is a member of
+type Collaboration struct {
+	ID        string `json:"id"`
+	VcsType   string `json:"vcs-type"`
+	Name      string `json:"name"`
+	Slug      string `json:"slug"`
+	AvatarURL string `json:"avatar_url"`
+}
@@ -0,0 +1,89 @@
+package circleci
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestParseProjectSlug(t *testing.T) {
+	cases := []struct {
+		input   string
+		vcs     string
+		org     string
+		project string
+		invalid bool
+	}{
+		{input: "gh/org/repo", vcs: "gh", org: "org", project: "repo"},
+		{input: "github/org/repo", vcs: "gh", org: "org", project: "repo"},
+		{input: "bitbucket/org/repo", vcs: "bb", org: "org", project: "repo"},
+		{input: "circleci/5c3a1b1e/9a0b6d4c", vcs: "circleci", org: "5c3a1b1e", project: "9a0b6d4c"},
+		{input: "gh/org", invalid: true},
+		{input: "gh/org/repo/extra", invalid: true},
+		{input: "svn/org/repo", invalid: true},
+		{input: "gh//repo", invalid: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			vcs, org, project, err := parseProjectSlug(tc.input)
+
+			if tc.invalid {
+				if err == nil {
+					t.Errorf("expected an error for %q", tc.input)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if vcs != tc.vcs || org != tc.org || project != tc.project {
+				t.Errorf("Slug was parsed incorrectly, got: %s %s %s, want: %s %s %s.", vcs, org, project, tc.vcs, tc.org, tc.project)
+			}
+		})
+	}
+}
+
+func TestV2ClientPagination(t *testing.T) {
+	var tokenHeaders []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/project/gh/org/repo/envvar" {
+			http.NotFound(w, r)
+			return
+		}
+
+		tokenHeaders = append(tokenHeaders, r.Header.Get(tokenHeader))
+
+		switch r.URL.Query().Get("page-token") {
+		case "":
+			fmt.Fprint(w, `{"items":[{"name":"A","value":"xxxx1"},{"name":"B","value":"xxxx2"}],"next_page_token":"page-2"}`)
+		case "page-2":
+			fmt.Fprint(w, `{"items":[{"name":"C","value":"xxxx3"}],"next_page_token":null}`)
+		default:
+			http.Error(w, `{"message":"bad page token"}`, http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	baseURL, _ := url.Parse(server.URL + "/api/v2/")
+	client := &ApiClient{V2BaseURL: baseURL, Token: "secret-token"}
+
+	envVars, err := client.V2().ListEnvVars(context.Background(), "gh/org/repo")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(envVars) != 3 || envVars[0].Name != "A" || envVars[2].Name != "C" {
+		t.Errorf("Environment variables were incorrect, got: %+v.", envVars)
+	}
+
+	if len(tokenHeaders) != 2 || tokenHeaders[0] != "secret-token" || tokenHeaders[1] != "secret-token" {
+		t.Errorf("Circle-Token headers were incorrect, got: %q.", tokenHeaders)
+	}
+}
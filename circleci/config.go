@@ -0,0 +1,212 @@
+package circleci
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+const (
+	defaultHost       = "https://circleci.com"
+	defaultRunnerHost = "https://runner.circleci.com"
+)
+
+// endpoints are the API base URLs derived from the host settings
+type endpoints struct {
+	v1     *url.URL
+	v2     *url.URL
+	runner *url.URL
+	server bool // a self-hosted CircleCI server install rather than circleci.com
+}
+
+// resolveEndpoints derives the API base URLs. apiBaseURL, when set, takes
+// precedence over host and points at the directory holding v1.1/ and v2/.
+// runnerHost defaults to runner.circleci.com on cloud and to the API host on
+// server, which serves the runner API itself.
+func resolveEndpoints(host, apiBaseURL, runnerHost string) (*endpoints, error) {
+	if host == "" {
+		host = defaultHost
+	}
+
+	var api *url.URL
+	var err error
+	if apiBaseURL != "" {
+		api, err = parseBaseURL(apiBaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid api_base_url: %s", err)
+		}
+	} else {
+		h, err := parseBaseURL(host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid host: %s", err)
+		}
+		api = h.ResolveReference(&url.URL{Path: "api/"})
+	}
+
+	e := &endpoints{
+		v1:     api.ResolveReference(&url.URL{Path: "v1.1/"}),
+		v2:     api.ResolveReference(&url.URL{Path: "v2/"}),
+		server: !isCloudHost(api),
+	}
+
+	if runnerHost == "" {
+		runnerHost = defaultRunnerHost
+		if e.server {
+			runnerHost = (&url.URL{Scheme: api.Scheme, Host: api.Host}).String()
+		}
+	}
+
+	runner, err := parseBaseURL(runnerHost)
+	if err != nil {
+		return nil, fmt.Errorf("invalid runner_host: %s", err)
+	}
+	e.runner = runner.ResolveReference(&url.URL{Path: "api/v3/"})
+
+	return e, nil
+}
+
+// parseBaseURL parses a host or URL, defaulting to https, and makes sure the
+// path ends with a slash so relative API paths resolve below it
+func parseBaseURL(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("%q has no host", raw)
+	}
+
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("%q must use http or https", raw)
+	}
+
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+
+	return u, nil
+}
+
+func isCloudHost(u *url.URL) bool {
+	return u.Hostname() == "circleci.com"
+}
+
+// httpConfig holds the transport settings of the provider HTTP client
+type httpConfig struct {
+	caCertFile         string
+	caCertPEM          string
+	clientCert         string // PEM or path to a PEM file
+	clientKey          string // PEM or path to a PEM file
+	insecureSkipVerify bool
+	proxyURL           string
+	timeout            time.Duration
+}
+
+// newHTTPClient builds the HTTP client used for every API call
+func newHTTPClient(cfg httpConfig) (*http.Client, error) {
+	transport := cleanhttp.DefaultPooledTransport()
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.insecureSkipVerify,
+	}
+
+	if cfg.caCertFile != "" || cfg.caCertPEM != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		if cfg.caCertFile != "" {
+			pem, err := ioutil.ReadFile(cfg.caCertFile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read ca_cert_file: %s", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("ca_cert_file %s contains no PEM encoded certificates", cfg.caCertFile)
+			}
+		}
+
+		if cfg.caCertPEM != "" && !pool.AppendCertsFromPEM([]byte(cfg.caCertPEM)) {
+			return nil, fmt.Errorf("ca_cert_pem contains no PEM encoded certificates")
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.clientCert != "" || cfg.clientKey != "" {
+		if cfg.clientCert == "" || cfg.clientKey == "" {
+			return nil, fmt.Errorf("client_cert and client_key must be set together")
+		}
+
+		certPEM, err := readPEM(cfg.clientCert)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read client_cert: %s", err)
+		}
+
+		keyPEM, err := readPEM(cfg.clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read client_key: %s", err)
+		}
+
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %s", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+
+	if cfg.proxyURL != "" {
+		proxy, err := url.Parse(cfg.proxyURL)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid proxy_url %q", cfg.proxyURL)
+		}
+
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   cfg.timeout,
+	}, nil
+}
+
+// readPEM returns value when it holds PEM data, and otherwise reads the file it
+// points at
+func readPEM(value string) ([]byte, error) {
+	if strings.Contains(value, "-----BEGIN") {
+		return []byte(value), nil
+	}
+
+	return ioutil.ReadFile(value)
+}
+
+// serverUnsupportedWarning warns, when talking to CircleCI server, that feature
+// relies on an endpoint only circleci.com exposes
+func (c *ApiClient) serverUnsupportedWarning(feature string) diag.Diagnostics {
+	if !c.Server {
+		return nil
+	}
+
+	return diag.Diagnostics{{
+		Severity: diag.Warning,
+		Summary:  fmt.Sprintf("%s is not available on CircleCI server", feature),
+		Detail:   fmt.Sprintf("The provider is configured for the CircleCI server install at %s, which does not expose the API used for %s. Requests are likely to fail.", c.v2BaseURL().Host, feature),
+	}}
+}
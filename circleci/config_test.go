@@ -0,0 +1,149 @@
+package circleci
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveEndpoints(t *testing.T) {
+	cases := []struct {
+		name       string
+		host       string
+		apiBaseURL string
+		runnerHost string
+		v1         string
+		v2         string
+		runner     string
+		server     bool
+		invalid    bool
+	}{
+		{
+			name:   "cloud",
+			v1:     "https://circleci.com/api/v1.1/",
+			v2:     "https://circleci.com/api/v2/",
+			runner: "https://runner.circleci.com/api/v3/",
+		},
+		{
+			name:   "server host",
+			host:   "circleci.example.com",
+			v1:     "https://circleci.example.com/api/v1.1/",
+			v2:     "https://circleci.example.com/api/v2/",
+			runner: "https://circleci.example.com/api/v3/",
+			server: true,
+		},
+		{
+			name:       "api base url",
+			host:       "https://circleci.com",
+			apiBaseURL: "http://proxy.internal:8080/circleci/api",
+			runnerHost: "runner.example.com",
+			v1:         "http://proxy.internal:8080/circleci/api/v1.1/",
+			v2:         "http://proxy.internal:8080/circleci/api/v2/",
+			runner:     "https://runner.example.com/api/v3/",
+			server:     true,
+		},
+		{
+			name:    "invalid scheme",
+			host:    "ftp://circleci.example.com",
+			invalid: true,
+		},
+		{
+			name:       "invalid runner host",
+			runnerHost: "ftp://runner.example.com",
+			invalid:    true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := resolveEndpoints(tc.host, tc.apiBaseURL, tc.runnerHost)
+
+			if tc.invalid {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if e.v1.String() != tc.v1 || e.v2.String() != tc.v2 || e.runner.String() != tc.runner || e.server != tc.server {
+				t.Errorf("Endpoints were incorrect, got: %s %s %s %t, want: %s %s %s %t.", e.v1, e.v2, e.runner, e.server, tc.v1, tc.v2, tc.runner, tc.server)
+			}
+		})
+	}
+}
+
+func TestNewHTTPClientCACert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`ok`))
+	}))
+	defer server.Close()
+
+	caCertPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	cases := []struct {
+		name    string
+		cfg     httpConfig
+		invalid bool
+	}{
+		{
+			name:    "untrusted",
+			cfg:     httpConfig{},
+			invalid: true,
+		},
+		{
+			name: "ca cert pem",
+			cfg:  httpConfig{caCertPEM: caCertPEM},
+		},
+		{
+			name: "insecure",
+			cfg:  httpConfig{insecureSkipVerify: true},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := newHTTPClient(tc.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			resp, err := client.Get(server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+
+			if tc.invalid && err == nil {
+				t.Error("expected a TLS error")
+			}
+
+			if !tc.invalid && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestNewHTTPClientInvalid(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  httpConfig
+	}{
+		{name: "ca cert pem", cfg: httpConfig{caCertPEM: "not a certificate"}},
+		{name: "ca cert file", cfg: httpConfig{caCertFile: "/nonexistent/ca.pem"}},
+		{name: "client cert without key", cfg: httpConfig{clientCert: "-----BEGIN CERTIFICATE-----"}},
+		{name: "proxy url", cfg: httpConfig{proxyURL: "not a url"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := newHTTPClient(tc.cfg); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package circleci
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// credentials is the API token and, when it came from the CircleCI CLI
+// configuration, the host that goes with it
+type credentials struct {
+	token  string
+	host   string
+	source string // where the token was found, for logs and error messages
+}
+
+// credentialsConfig holds the provider arguments credentials are resolved from
+type credentialsConfig struct {
+	token       string
+	tokenFile   string
+	cliYAMLPath string // defaults to ~/.circleci/cli.yml
+}
+
+// resolveCredentials looks for an API token in, by order of precedence:
+//
+//  1. the api_token argument
+//  2. the file named by the api_token_file argument
+//  3. the CIRCLECI_API_TOKEN environment variable
+//  4. the CIRCLE_TOKEN environment variable
+//  5. the token of the CircleCI CLI configuration, ~/.circleci/cli.yml
+//
+// It returns nil credentials when no token was found.
+func resolveCredentials(cfg credentialsConfig) (*credentials, error) {
+	if cfg.token != "" {
+		return &credentials{token: cfg.token, source: "api_token"}, nil
+	}
+
+	if cfg.tokenFile != "" {
+		b, err := ioutil.ReadFile(cfg.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read api_token_file: %s", err)
+		}
+
+		token := strings.TrimSpace(string(b))
+		if token == "" {
+			return nil, fmt.Errorf("api_token_file %s is empty", cfg.tokenFile)
+		}
+
+		return &credentials{token: token, source: "api_token_file"}, nil
+	}
+
+	for _, env := range []string{"CIRCLECI_API_TOKEN", "CIRCLE_TOKEN"} {
+		if token := os.Getenv(env); token != "" {
+			return &credentials{token: token, source: env}, nil
+		}
+	}
+
+	path := cfg.cliYAMLPath
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil
+		}
+		path = filepath.Join(home, ".circleci", "cli.yml")
+	}
+
+	cli, err := readCLIConfig(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CircleCI CLI configuration %s: %s", path, err)
+	}
+
+	if cli["token"] == "" {
+		return nil, nil
+	}
+
+	return &credentials{token: cli["token"], host: cli["host"], source: path}, nil
+}
+
+// readCLIConfig reads the top-level scalar settings of the CircleCI CLI
+// configuration. The file is a flat YAML mapping, e.g.
+//
+//	host: https://circleci.com
+//	token: 0123456789abcdef
+func readCLIConfig(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	settings := map[string]string{}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// skip comments, blank lines and anything nested
+		if strings.HasPrefix(strings.TrimSpace(line), "#") || strings.TrimSpace(line) == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		settings[strings.TrimSpace(parts[0])] = unquoteYAML(strings.TrimSpace(parts[1]))
+	}
+
+	return settings, scanner.Err()
+}
+
+func unquoteYAML(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+
+	return value
+}
@@ -0,0 +1,97 @@
+package circleci
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveCredentials(t *testing.T) {
+	dir := t.TempDir()
+
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cliYAML := filepath.Join(dir, "cli.yml")
+	cliConfig := `# CircleCI CLI configuration
+host: https://circleci.example.com
+endpoint: graphql-unstable
+token: "cli-token"
+tls_cert: ""
+`
+	if err := ioutil.WriteFile(cliYAML, []byte(cliConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name          string
+		cfg           credentialsConfig
+		env           map[string]string
+		expectedToken string
+		expectedHost  string
+	}{
+		{
+			name:          "argument",
+			cfg:           credentialsConfig{token: "arg-token", tokenFile: tokenFile, cliYAMLPath: cliYAML},
+			env:           map[string]string{"CIRCLECI_API_TOKEN": "env-token"},
+			expectedToken: "arg-token",
+		},
+		{
+			name:          "token file",
+			cfg:           credentialsConfig{tokenFile: tokenFile, cliYAMLPath: cliYAML},
+			env:           map[string]string{"CIRCLECI_API_TOKEN": "env-token"},
+			expectedToken: "file-token",
+		},
+		{
+			name:          "CIRCLECI_API_TOKEN",
+			cfg:           credentialsConfig{cliYAMLPath: cliYAML},
+			env:           map[string]string{"CIRCLECI_API_TOKEN": "env-token", "CIRCLE_TOKEN": "circle-token"},
+			expectedToken: "env-token",
+		},
+		{
+			name:          "CIRCLE_TOKEN",
+			cfg:           credentialsConfig{cliYAMLPath: cliYAML},
+			env:           map[string]string{"CIRCLE_TOKEN": "circle-token"},
+			expectedToken: "circle-token",
+		},
+		{
+			name:          "cli config",
+			cfg:           credentialsConfig{cliYAMLPath: cliYAML},
+			expectedToken: "cli-token",
+			expectedHost:  "https://circleci.example.com",
+		},
+		{
+			name: "none",
+			cfg:  credentialsConfig{cliYAMLPath: filepath.Join(dir, "missing.yml")},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("CIRCLECI_API_TOKEN", tc.env["CIRCLECI_API_TOKEN"])
+			t.Setenv("CIRCLE_TOKEN", tc.env["CIRCLE_TOKEN"])
+
+			creds, err := resolveCredentials(tc.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if tc.expectedToken == "" {
+				if creds != nil {
+					t.Errorf("expected no credentials, got token from %s", creds.source)
+				}
+				return
+			}
+
+			if creds == nil {
+				t.Fatal("expected credentials")
+			}
+
+			if creds.token != tc.expectedToken || creds.host != tc.expectedHost {
+				t.Errorf("Credentials were incorrect, got: %s %s, want: %s %s.", creds.token, creds.host, tc.expectedToken, tc.expectedHost)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package circleci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataSourceContext() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceContextRead,
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "Name of the context.",
+			},
+			"owner_id": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"owner_id", "owner_slug"},
+				Description:  "ID of the organization or account owning the context.",
+			},
+			"owner_slug": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"owner_id", "owner_slug"},
+				Description:  "Slug of the organization or account owning the context, e.g. gh/org.",
+			},
+			"owner_type": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      contextOwnerOrganization,
+				Description:  "Type of the owner, organization or account.",
+				ValidateFunc: validateContextOwnerType,
+			},
+			"created_at": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Creation time of the context, in RFC 3339 format.",
+			},
+			"environment_variables": contextEnvironmentVariablesSchema(),
+		},
+	}
+}
+
+func dataSourceContextRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*ApiClient)
+
+	name := d.Get("name").(string)
+
+	c, err := client.V2().FindContext(ctx, contextOwner(d), name)
+	if errors.Is(err, ErrNotFound) {
+		return diag.Errorf("CircleCI context %q not found", name)
+	}
+	if err != nil {
+		return apiErrorDiagnostics("Error reading context", fmt.Sprintf("Unable to find CircleCI context %q", name), err, nil)
+	}
+
+	envVars, err := client.V2().ListContextEnvVars(ctx, c.ID)
+	if err != nil {
+		return apiErrorDiagnostics("Error reading context", fmt.Sprintf("Unable to list environment variables of CircleCI context %q", name), err, nil)
+	}
+
+	d.SetId(c.ID)
+	d.Set("created_at", c.CreatedAt.Format(time.RFC3339))
+	if err := d.Set("environment_variables", flattenContextEnvVars(envVars)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
+
+// contextEnvironmentVariablesSchema describes the variables of a context, which
+// data sources expose without their values
+func contextEnvironmentVariablesSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Computed:    true,
+		Description: "Environment variables of the context, sorted by name. CircleCI never returns their values.",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"name": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"created_at": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"updated_at": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+			},
+		},
+	}
+}
+
+// flattenContextEnvVars returns the names and timestamps of context variables,
+// sorted by name
+func flattenContextEnvVars(envVars []ContextEnvVar) []interface{} {
+	sort.Slice(envVars, func(i, j int) bool { return envVars[i].Variable < envVars[j].Variable })
+
+	result := make([]interface{}, 0, len(envVars))
+	for _, v := range envVars {
+		result = append(result, map[string]interface{}{
+			"name":       v.Variable,
+			"created_at": v.CreatedAt.Format(time.RFC3339),
+			"updated_at": v.UpdatedAt.Format(time.RFC3339),
+		})
+	}
+
+	return result
+}
@@ -0,0 +1,51 @@
+package circleci
+
+import (
+	"testing"
+)
+
+func TestDataSourceContext(t *testing.T) {
+	api := newFakeAPI(t)
+	id := api.addContext("deploy", ContextOwner{Slug: "gh/org", Type: "organization"})
+	api.addContext("deploy", ContextOwner{Slug: "gh/other", Type: "organization"})
+	api.setContextEnvVar(id, "REGISTRY_USER", "deploy")
+	api.setContextEnvVar(id, "REGISTRY_PASSWORD", "secret")
+	client := api.client()
+
+	state, diags := testReadDataSource(t, dataSourceContext(), map[string]interface{}{
+		"name":       "deploy",
+		"owner_slug": "gh/org",
+	}, client)
+	if diags.HasError() {
+		t.Fatalf("unexpected error reading: %+v", diags)
+	}
+
+	expected := map[string]string{
+		"id":                                 id,
+		"created_at":                         "2021-06-01T12:00:01Z",
+		"environment_variables.#":            "2",
+		"environment_variables.0.name":       "REGISTRY_PASSWORD",
+		"environment_variables.0.created_at": "2021-06-02T00:00:02Z",
+		"environment_variables.0.updated_at": "2021-06-02T00:00:02Z",
+		"environment_variables.1.name":       "REGISTRY_USER",
+	}
+	for k, v := range expected {
+		if state.Attributes[k] != v {
+			t.Errorf("%s was incorrect, got: %s, want: %s.", k, state.Attributes[k], v)
+		}
+	}
+
+	for k, v := range state.Attributes {
+		if v == "secret" {
+			t.Errorf("%s holds the value of a variable.", k)
+		}
+	}
+
+	_, diags = testReadDataSource(t, dataSourceContext(), map[string]interface{}{
+		"name":       "missing",
+		"owner_slug": "gh/org",
+	}, client)
+	if !diags.HasError() || diags[0].Summary != `CircleCI context "missing" not found` {
+		t.Errorf("Expected a missing context to be an error, got: %+v.", diags)
+	}
+}
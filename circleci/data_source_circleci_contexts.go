@@ -0,0 +1,120 @@
+package circleci
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataSourceContexts() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceContextsRead,
+
+		Schema: map[string]*schema.Schema{
+			"owner_id": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"owner_id", "owner_slug"},
+				Description:  "ID of the organization or account owning the contexts.",
+			},
+			"owner_slug": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"owner_id", "owner_slug"},
+				Description:  "Slug of the organization or account owning the contexts, e.g. gh/org.",
+			},
+			"owner_type": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      contextOwnerOrganization,
+				Description:  "Type of the owner, organization or account.",
+				ValidateFunc: validateContextOwnerType,
+			},
+			"name_regex": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateRegexp,
+				Description:  "Regular expression the names of the contexts must match.",
+			},
+			"ids": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "IDs of the contexts, sorted by name.",
+			},
+			"contexts": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Contexts, sorted by name.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"created_at": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"environment_variables": contextEnvironmentVariablesSchema(),
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceContextsRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*ApiClient)
+
+	owner := contextOwner(d)
+
+	// validated by validateRegexp
+	nameRegex, _ := regexp.Compile(d.Get("name_regex").(string))
+
+	contexts, err := client.V2().ListContexts(ctx, owner)
+	if err != nil {
+		return apiErrorDiagnostics("Error reading contexts", "Unable to list CircleCI contexts", err, nil)
+	}
+
+	ids := make([]interface{}, 0, len(contexts))
+	result := make([]interface{}, 0, len(contexts))
+
+	sort.Slice(contexts, func(i, j int) bool { return contexts[i].Name < contexts[j].Name })
+
+	for _, c := range contexts {
+		if !nameRegex.MatchString(c.Name) {
+			continue
+		}
+
+		envVars, err := client.V2().ListContextEnvVars(ctx, c.ID)
+		if err != nil {
+			return apiErrorDiagnostics("Error reading contexts", fmt.Sprintf("Unable to list environment variables of CircleCI context %q", c.Name), err, nil)
+		}
+
+		ids = append(ids, c.ID)
+		result = append(result, map[string]interface{}{
+			"id":                    c.ID,
+			"name":                  c.Name,
+			"created_at":            c.CreatedAt.Format(time.RFC3339),
+			"environment_variables": flattenContextEnvVars(envVars),
+		})
+	}
+
+	d.SetId(fmt.Sprintf("%s:%s%s:%s", owner.Type, owner.ID, owner.Slug, nameRegex))
+	d.Set("ids", ids)
+	if err := d.Set("contexts", result); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return nil
+}
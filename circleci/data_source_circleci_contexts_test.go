@@ -0,0 +1,52 @@
+package circleci
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestDataSourceContexts(t *testing.T) {
+	api := newFakeAPI(t)
+	production := api.addContext("deploy-production", ContextOwner{Slug: "gh/org", Type: "organization"})
+	api.addContext("aws", ContextOwner{Slug: "gh/org", Type: "organization"})
+	staging := api.addContext("deploy-staging", ContextOwner{Slug: "gh/org", Type: "organization"})
+	api.addContext("deploy-other", ContextOwner{Slug: "gh/other", Type: "organization"})
+	api.setContextEnvVar(staging, "TOKEN", "secret")
+	client := api.client()
+
+	state, diags := testReadDataSource(t, dataSourceContexts(), map[string]interface{}{
+		"owner_slug": "gh/org",
+		"name_regex": "^deploy-",
+	}, client)
+	if diags.HasError() {
+		t.Fatalf("unexpected error reading: %+v", diags)
+	}
+
+	expected := map[string]string{
+		"ids.#":                              "2",
+		"ids.0":                              production,
+		"ids.1":                              staging,
+		"contexts.#":                         "2",
+		"contexts.0.name":                    "deploy-production",
+		"contexts.0.environment_variables.#": "0",
+		"contexts.1.id":                      staging,
+		"contexts.1.created_at":              "2021-06-01T12:00:03Z",
+		"contexts.1.environment_variables.#": "1",
+		"contexts.1.environment_variables.0.name": "TOKEN",
+	}
+	for k, v := range expected {
+		if state.Attributes[k] != v {
+			t.Errorf("%s was incorrect, got: %s, want: %s.", k, state.Attributes[k], v)
+		}
+	}
+
+	state, diags = testReadDataSource(t, dataSourceContexts(), map[string]interface{}{"owner_slug": "gh/org"}, client)
+	if diags.HasError() || state.Attributes["ids.#"] != "3" {
+		t.Errorf("Expected every context without a filter, got: %v %+v.", state, diags)
+	}
+
+	if diags := dataSourceContexts().Validate(terraform.NewResourceConfigRaw(map[string]interface{}{"owner_slug": "gh/org", "name_regex": "deploy-("})); !diags.HasError() {
+		t.Error("Expected an error for an invalid regular expression.")
+	}
+}
@@ -0,0 +1,48 @@
+package circleci
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataSourceMe() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceMeRead,
+
+		Schema: map[string]*schema.Schema{
+			"login": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Login of the user the API token belongs to.",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Name of the user the API token belongs to.",
+			},
+		},
+	}
+}
+
+// dataSourceMeRead reuses the user found while validating the token, and only
+// asks CircleCI when validation was skipped
+func dataSourceMeRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*ApiClient)
+
+	user := client.CurrentUser
+	if user == nil {
+		var err error
+		user, err = client.V2().Me(ctx)
+		if err != nil {
+			return apiErrorDiagnostics("Error reading current user", "Unable to read the user the CircleCI API token belongs to", err, nil)
+		}
+	}
+
+	d.SetId(user.ID)
+	d.Set("login", user.Login)
+	d.Set("name", user.Name)
+
+	return nil
+}
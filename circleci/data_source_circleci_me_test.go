@@ -0,0 +1,27 @@
+package circleci
+
+import (
+	"testing"
+)
+
+func TestDataSourceMe(t *testing.T) {
+	api := newFakeAPI(t)
+	client := api.client()
+
+	state, diags := testReadDataSource(t, dataSourceMe(), map[string]interface{}{}, client)
+	if diags.HasError() {
+		t.Fatalf("unexpected error reading: %+v", diags)
+	}
+
+	if state.ID != "user-id" || state.Attributes["login"] != "fake-user" || state.Attributes["name"] != "Fake User" {
+		t.Errorf("User was incorrect, got: %s %v.", state.ID, state.Attributes)
+	}
+
+	// the user found validating the token is reused
+	client.CurrentUser = &User{ID: "validated-id", Login: "validated", Name: "Validated"}
+	state, _ = testReadDataSource(t, dataSourceMe(), map[string]interface{}{}, client)
+
+	if state.ID != "validated-id" || api.countRequests("GET /api/v2/me") != 1 {
+		t.Errorf("Expected the validated user to be reused, got: %s after %d requests.", state.ID, api.countRequests("GET /api/v2/me"))
+	}
+}
@@ -0,0 +1,119 @@
+package circleci
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataSourceOrganization() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceOrganizationRead,
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ExactlyOneOf: []string{"name", "slug"},
+				Description:  "Name of the organization.",
+			},
+			"vcs_type": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Computed:      true,
+				ConflictsWith: []string{"slug"},
+				Description:   "VCS type of the organization, github, bitbucket or circleci. Only needed when organizations of different VCS types share the name.",
+				ValidateFunc: func(v interface{}, k string) (ws []string, errs []error) {
+					switch v.(string) {
+					case "github", "bitbucket", "circleci":
+					default:
+						errs = append(errs, fmt.Errorf("Value of %s must be one of github, bitbucket or circleci.", k))
+					}
+					return
+				},
+			},
+			"slug": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ExactlyOneOf: []string{"name", "slug"},
+				Description:  "Slug of the organization, e.g. gh/org.",
+			},
+			"avatar_url": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "URL of the avatar of the organization.",
+			},
+		},
+	}
+}
+
+// dataSourceOrganizationRead looks the organization up among the ones the user
+// the API token belongs to is a member of
+func dataSourceOrganizationRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*ApiClient)
+
+	orgs, err := client.V2().ListCollaborations(ctx)
+	if err != nil {
+		return apiErrorDiagnostics("Error reading organization", "Unable to list the organizations of the CircleCI user", err, nil)
+	}
+
+	name := d.Get("name").(string)
+	vcsType := d.Get("vcs_type").(string)
+	slug := d.Get("slug").(string)
+
+	var matches []Collaboration
+	for _, org := range orgs {
+		switch {
+		case slug != "" && equalOrganizationSlugs(org.Slug, slug):
+			matches = append(matches, org)
+		case slug == "" && strings.EqualFold(org.Name, name) && (vcsType == "" || org.VcsType == vcsType):
+			matches = append(matches, org)
+		}
+	}
+
+	search := slug
+	if search == "" {
+		search = name
+	}
+
+	switch len(matches) {
+	case 0:
+		return diag.Errorf("CircleCI organization %q not found among the organizations of the user the API token belongs to", search)
+	case 1:
+	default:
+		return diag.Errorf("Several CircleCI organizations are named %q, set vcs_type to choose one", search)
+	}
+
+	org := matches[0]
+
+	d.SetId(org.ID)
+	d.Set("name", org.Name)
+	d.Set("vcs_type", org.VcsType)
+	d.Set("slug", org.Slug)
+	d.Set("avatar_url", org.AvatarURL)
+
+	return nil
+}
+
+// equalOrganizationSlugs reports whether two slugs name the same organization,
+// e.g. gh/org and github/org
+func equalOrganizationSlugs(a, b string) bool {
+	return strings.EqualFold(expandOrganizationSlug(a), expandOrganizationSlug(b))
+}
+
+func expandOrganizationSlug(slug string) string {
+	parts := strings.SplitN(slug, "/", 2)
+	switch parts[0] {
+	case "github":
+		parts[0] = "gh"
+	case "bitbucket":
+		parts[0] = "bb"
+	}
+
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,53 @@
+package circleci
+
+import (
+	"testing"
+)
+
+func TestDataSourceOrganization(t *testing.T) {
+	api := newFakeAPI(t)
+	github := api.addOrganization("github", "org")
+	bitbucket := api.addOrganization("bitbucket", "org")
+	other := api.addOrganization("github", "other")
+	client := api.client()
+
+	cases := []struct {
+		name     string
+		config   map[string]interface{}
+		expected string
+	}{
+		{name: "vcs type and name", config: map[string]interface{}{"vcs_type": "bitbucket", "name": "org"}, expected: bitbucket},
+		{name: "name", config: map[string]interface{}{"name": "Other"}, expected: other},
+		{name: "slug", config: map[string]interface{}{"slug": "gh/org"}, expected: github},
+		{name: "long slug", config: map[string]interface{}{"slug": "github/org"}, expected: github},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			state, diags := testReadDataSource(t, dataSourceOrganization(), tc.config, client)
+			if diags.HasError() {
+				t.Fatalf("unexpected error reading: %+v", diags)
+			}
+
+			if state.ID != tc.expected {
+				t.Errorf("ID was incorrect, got: %s, want: %s.", state.ID, tc.expected)
+			}
+		})
+	}
+
+	state, _ := testReadDataSource(t, dataSourceOrganization(), map[string]interface{}{"slug": "gh/org"}, client)
+	expected := map[string]string{"name": "org", "vcs_type": "github", "slug": "gh/org", "avatar_url": "https://avatars.example.com/org"}
+	for k, v := range expected {
+		if state.Attributes[k] != v {
+			t.Errorf("%s was incorrect, got: %s, want: %s.", k, state.Attributes[k], v)
+		}
+	}
+
+	if _, diags := testReadDataSource(t, dataSourceOrganization(), map[string]interface{}{"name": "org"}, client); !diags.HasError() {
+		t.Error("Expected an error for a name shared by several organizations.")
+	}
+
+	if _, diags := testReadDataSource(t, dataSourceOrganization(), map[string]interface{}{"slug": "gh/missing"}, client); !diags.HasError() {
+		t.Error("Expected an error for a missing organization.")
+	}
+}
@@ -0,0 +1,123 @@
+package circleci
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var envVarNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// dotenvError is a syntax error of dotenv content. It never quotes the content,
+// which holds secrets.
+type dotenvError struct {
+	line int
+	msg  string
+}
+
+func (e *dotenvError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.msg)
+}
+
+// parseDotenv parses the variables of dotenv content, e.g.
+//
+//	# comment
+//	export FOO=bar
+//	QUOTED="line one\nline two"
+//	LITERAL='no $expansion or \escapes'
+//	MULTILINE="-----BEGIN CERTIFICATE-----
+//	...
+//	-----END CERTIFICATE-----"
+//
+// Values aren't expanded. Double quoted values support the \n, \r, \t, \", \\
+// and \$ escapes, and both quoted forms may span several lines.
+func parseDotenv(content string) (map[string]string, error) {
+	vars := map[string]string{}
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		lineNumber := i + 1
+		line := strings.TrimSpace(lines[i])
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "export ") || strings.HasPrefix(line, "export\t") {
+			line = strings.TrimSpace(line[len("export"):])
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, &dotenvError{lineNumber, "expected NAME=value"}
+		}
+
+		name := strings.TrimSpace(line[:eq])
+		if !envVarNamePattern.MatchString(name) {
+			// the line may be a misplaced value, so it isn't quoted
+			return nil, &dotenvError{lineNumber, "invalid variable name"}
+		}
+
+		if _, ok := vars[name]; ok {
+			return nil, &dotenvError{lineNumber, fmt.Sprintf("duplicate variable %s", name)}
+		}
+
+		rest := strings.TrimSpace(line[eq+1:])
+
+		if rest == "" || (rest[0] != '"' && rest[0] != '\'') {
+			if j := strings.Index(rest, " #"); j >= 0 {
+				rest = strings.TrimSpace(rest[:j])
+			}
+			vars[name] = rest
+			continue
+		}
+
+		// a quoted value runs until the closing quote, possibly on a later line
+		quote := rest[0]
+		value := rest[1:]
+		end := closingQuote(value, quote)
+		for end < 0 && i+1 < len(lines) {
+			i++
+			value += "\n" + lines[i]
+			end = closingQuote(value, quote)
+		}
+
+		if end < 0 {
+			return nil, &dotenvError{lineNumber, fmt.Sprintf("unterminated quoted value of %s", name)}
+		}
+
+		if trailing := strings.TrimSpace(value[end+1:]); trailing != "" && !strings.HasPrefix(trailing, "#") {
+			return nil, &dotenvError{i + 1, fmt.Sprintf("unexpected characters after the quoted value of %s", name)}
+		}
+
+		value = value[:end]
+		if quote == '"' {
+			value = unescapeDotenv(value)
+		}
+
+		vars[name] = value
+	}
+
+	return vars, nil
+}
+
+// closingQuote returns the index of the quote closing s, skipping escaped
+// quotes in double quoted values, or -1
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote == '"':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+
+	return -1
+}
+
+var dotenvEscapes = strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t", `\"`, `"`, `\\`, `\`, `\$`, `$`)
+
+func unescapeDotenv(s string) string {
+	return dotenvEscapes.Replace(s)
+}
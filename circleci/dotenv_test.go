@@ -0,0 +1,66 @@
+package circleci
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	content := `# registry credentials
+export REGISTRY_USER=deploy
+REGISTRY_PASSWORD = "p@ss \"word\"\n" # trailing comment
+LITERAL='no \n $escapes'
+UNQUOTED=value # comment
+EMPTY=
+
+CERTIFICATE="-----BEGIN CERTIFICATE-----
+MIIB
+-----END CERTIFICATE-----"
+`
+
+	vars, err := parseDotenv(content)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := map[string]string{
+		"REGISTRY_USER":     "deploy",
+		"REGISTRY_PASSWORD": "p@ss \"word\"\n",
+		"LITERAL":           `no \n $escapes`,
+		"UNQUOTED":          "value",
+		"EMPTY":             "",
+		"CERTIFICATE":       "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----",
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Variables were incorrect, got: %q, want: %q.", vars, expected)
+	}
+}
+
+func TestParseDotenvInvalid(t *testing.T) {
+	cases := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{name: "missing equals", content: "FOO=bar\nsecret", expected: "line 2: expected NAME=value"},
+		{name: "invalid name", content: "\n1FOO=bar", expected: "line 2: invalid variable name"},
+		{name: "value as name", content: "FOO=bar\nsecret-token==", expected: "line 2: invalid variable name"},
+		{name: "duplicate", content: "FOO=bar\nFOO=baz", expected: "line 2: duplicate variable FOO"},
+		{name: "unterminated", content: "A=1\nFOO=\"secret\nmore", expected: "line 2: unterminated quoted value of FOO"},
+		{name: "trailing", content: "FOO='a\nb' secret", expected: "line 2: unexpected characters after the quoted value of FOO"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseDotenv(tc.content)
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("Error was incorrect, got: %v, want: %s.", err, tc.expected)
+			}
+
+			if strings.Contains(err.Error(), "secret") {
+				t.Errorf("Error quotes the content: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package circleci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// Sentinel errors matching the APIError of the corresponding HTTP status, for use
+// with errors.Is
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+)
+
+// maxErrorBodyLength limits how much of a non-JSON error response ends up in an error message
+const maxErrorBodyLength = 200
+
+// APIError represents an error from CircleCI
+type APIError struct {
+	HTTPStatusCode int
+	Message        string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%d: %s", e.HTTPStatusCode, http.StatusText(e.HTTPStatusCode))
+	}
+
+	return fmt.Sprintf("%d: %s", e.HTTPStatusCode, e.Message)
+}
+
+// Is reports whether the error matches one of the sentinel errors, e.g.
+// errors.Is(err, ErrNotFound) for a 404 response
+func (e *APIError) Is(target error) bool {
+	return target != nil && target == e.kind()
+}
+
+func (e *APIError) kind() error {
+	switch e.HTTPStatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return ErrValidation
+	}
+
+	return nil
+}
+
+// newAPIError builds the APIError for an error response, using the message
+// CircleCI sends in the body when there is one
+func newAPIError(statusCode int, body []byte) *APIError {
+	body = []byte(strings.TrimSpace(string(body)))
+	if len(body) == 0 {
+		return &APIError{HTTPStatusCode: statusCode}
+	}
+
+	message := struct {
+		Message string `json:"message"`
+	}{}
+	if err := json.Unmarshal(body, &message); err != nil {
+		text := string(body)
+		if len(text) > maxErrorBodyLength {
+			text = text[:maxErrorBodyLength] + "..."
+		}
+
+		return &APIError{HTTPStatusCode: statusCode, Message: fmt.Sprintf("unable to parse API response: %s", text)}
+	}
+
+	return &APIError{HTTPStatusCode: statusCode, Message: message.Message}
+}
+
+// apiErrorDiagnostics reports a failed API call. Authentication and permission
+// failures are about the provider credentials rather than the resource, so they
+// get a summary of their own and no attribute path.
+func apiErrorDiagnostics(summary, detail string, err error, path cty.Path) diag.Diagnostics {
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "CircleCI rejected the API token",
+			Detail:   fmt.Sprintf("%s: %s\n\nCheck the api_token provider argument or the CIRCLECI_API_TOKEN environment variable.", detail, err),
+		}}
+	case errors.Is(err, ErrForbidden):
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "CircleCI API token lacks permission",
+			Detail:   fmt.Sprintf("%s: %s\n\nThe user the API token belongs to is not allowed to perform this operation.", detail, err),
+		}}
+	}
+
+	return diag.Diagnostics{{
+		Severity:      diag.Error,
+		Summary:       summary,
+		Detail:        fmt.Sprintf("%s: %s", detail, err),
+		AttributePath: path,
+	}}
+}
@@ -0,0 +1,79 @@
+package circleci
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	cases := []struct {
+		status   int
+		expected error
+	}{
+		{status: 400, expected: ErrValidation},
+		{status: 401, expected: ErrUnauthorized},
+		{status: 403, expected: ErrForbidden},
+		{status: 404, expected: ErrNotFound},
+		{status: 409, expected: ErrConflict},
+		{status: 422, expected: ErrValidation},
+		{status: 429, expected: ErrRateLimited},
+	}
+
+	sentinels := []error{ErrNotFound, ErrUnauthorized, ErrForbidden, ErrRateLimited, ErrConflict, ErrValidation}
+
+	for _, tc := range cases {
+		t.Run(fmt.Sprint(tc.status), func(t *testing.T) {
+			err := fmt.Errorf("wrapped: %w", &APIError{HTTPStatusCode: tc.status})
+
+			for _, sentinel := range sentinels {
+				if got := errors.Is(err, sentinel); got != (sentinel == tc.expected) {
+					t.Errorf("errors.Is(%d, %q) was incorrect, got: %t.", tc.status, sentinel, got)
+				}
+			}
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.HTTPStatusCode != tc.status {
+				t.Errorf("errors.As did not find the APIError for %d.", tc.status)
+			}
+		})
+	}
+
+	if errors.Is(&APIError{HTTPStatusCode: 500}, ErrNotFound) {
+		t.Error("a server error matched ErrNotFound")
+	}
+}
+
+func TestNewAPIError(t *testing.T) {
+	cases := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "message",
+			body:     `{"message":"Project not found"}`,
+			expected: "404: Project not found",
+		},
+		{
+			name:     "empty",
+			body:     "",
+			expected: "404: Not Found",
+		},
+		{
+			name:     "not json",
+			body:     "<html>gateway</html>",
+			expected: "404: unable to parse API response: <html>gateway</html>",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := newAPIError(404, []byte(tc.body)).Error()
+
+			if result != tc.expected {
+				t.Errorf("Error message was incorrect, got: %s, want: %s.", result, tc.expected)
+			}
+		})
+	}
+}
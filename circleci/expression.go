@@ -0,0 +1,248 @@
+package circleci
+
+import (
+	"fmt"
+	"strings"
+)
+
+// expressionError is a syntax error of a context restriction expression
+type expressionError struct {
+	column int
+	msg    string
+}
+
+func (e *expressionError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.column, e.msg)
+}
+
+type expressionTokenKind int
+
+const (
+	expressionEOF expressionTokenKind = iota
+	expressionIdentifier
+	expressionKeyword
+	expressionString
+	expressionNumber
+	expressionOperator
+	expressionParen
+)
+
+type expressionToken struct {
+	kind   expressionTokenKind
+	text   string
+	column int
+}
+
+// parseRestrictionExpression checks the syntax of a context restriction
+// expression, e.g.
+//
+//	pipeline.git.branch == "main" and not (job.ssh.enabled or pipeline.git.tag =~ "^v.*")
+//
+// Expressions combine comparisons (==, !=, <, <=, >, >=, starts-with, and =~
+// against a regular expression string) of pipeline values and literals with
+// and, or, not and parentheses. The regular expressions themselves are checked by
+// CircleCI, whose syntax isn't Go's.
+func parseRestrictionExpression(expr string) error {
+	tokens, err := tokenizeExpression(expr)
+	if err != nil {
+		return err
+	}
+
+	p := &expressionParser{tokens: tokens}
+	if err := p.or(); err != nil {
+		return err
+	}
+
+	if t := p.peek(); t.kind != expressionEOF {
+		return &expressionError{t.column, fmt.Sprintf("unexpected %q", t.text)}
+	}
+
+	return nil
+}
+
+type expressionParser struct {
+	tokens []expressionToken
+	pos    int
+}
+
+func (p *expressionParser) peek() expressionToken {
+	return p.tokens[p.pos]
+}
+
+func (p *expressionParser) next() expressionToken {
+	t := p.tokens[p.pos]
+	if t.kind != expressionEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *expressionParser) keyword(word string) bool {
+	if t := p.peek(); t.kind == expressionKeyword && t.text == word {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *expressionParser) or() error {
+	if err := p.and(); err != nil {
+		return err
+	}
+
+	for p.keyword("or") {
+		if err := p.and(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *expressionParser) and() error {
+	if err := p.not(); err != nil {
+		return err
+	}
+
+	for p.keyword("and") {
+		if err := p.not(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *expressionParser) not() error {
+	if p.keyword("not") {
+		return p.not()
+	}
+
+	return p.comparison()
+}
+
+func (p *expressionParser) comparison() error {
+	if err := p.operand(); err != nil {
+		return err
+	}
+
+	if p.peek().kind != expressionOperator {
+		return nil
+	}
+
+	op := p.next()
+	if op.text == "=~" {
+		if t := p.next(); t.kind != expressionString {
+			return &expressionError{t.column, fmt.Sprintf("expected a regular expression string after =~, got %s", describeExpressionToken(t))}
+		}
+		return nil
+	}
+
+	return p.operand()
+}
+
+func (p *expressionParser) operand() error {
+	t := p.next()
+
+	switch {
+	case t.kind == expressionParen && t.text == "(":
+		if err := p.or(); err != nil {
+			return err
+		}
+		if closing := p.next(); closing.kind != expressionParen || closing.text != ")" {
+			return &expressionError{closing.column, fmt.Sprintf("expected ), got %s", describeExpressionToken(closing))}
+		}
+		return nil
+	case t.kind == expressionIdentifier, t.kind == expressionString, t.kind == expressionNumber:
+		return nil
+	case t.kind == expressionKeyword && (t.text == "true" || t.text == "false"):
+		return nil
+	}
+
+	return &expressionError{t.column, fmt.Sprintf("expected a value, got %s", describeExpressionToken(t))}
+}
+
+func describeExpressionToken(t expressionToken) string {
+	if t.kind == expressionEOF {
+		return "end of expression"
+	}
+
+	return fmt.Sprintf("%q", t.text)
+}
+
+var expressionKeywords = map[string]bool{"and": true, "or": true, "not": true, "true": true, "false": true}
+
+// expressionWordOperators are the operators spelled like names
+var expressionWordOperators = map[string]bool{"starts-with": true}
+
+// tokenizeExpression splits an expression into tokens, ending with an EOF one
+func tokenizeExpression(expr string) ([]expressionToken, error) {
+	var tokens []expressionToken
+
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		column := i + 1
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, expressionToken{expressionParen, string(c), column})
+			i++
+		case strings.HasPrefix(expr[i:], "==") || strings.HasPrefix(expr[i:], "!=") || strings.HasPrefix(expr[i:], "=~") ||
+			strings.HasPrefix(expr[i:], "<=") || strings.HasPrefix(expr[i:], ">="):
+			tokens = append(tokens, expressionToken{expressionOperator, expr[i : i+2], column})
+			i += 2
+		case c == '<' || c == '>':
+			tokens = append(tokens, expressionToken{expressionOperator, string(c), column})
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for ; end < len(expr) && expr[end] != c; end++ {
+				if expr[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(expr) {
+				return nil, &expressionError{column, "unterminated string"}
+			}
+			tokens = append(tokens, expressionToken{expressionString, expr[i : end+1], column})
+			i = end + 1
+		case c >= '0' && c <= '9':
+			end := i
+			for end < len(expr) && (expr[end] >= '0' && expr[end] <= '9' || expr[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, expressionToken{expressionNumber, expr[i:end], column})
+			i = end
+		case isExpressionNameStart(c):
+			end := i
+			for end < len(expr) && (isExpressionNameStart(expr[end]) || expr[end] >= '0' && expr[end] <= '9' || expr[end] == '-' || expr[end] == '.') {
+				end++
+			}
+			text := expr[i:end]
+			if strings.HasSuffix(text, ".") || strings.Contains(text, "..") {
+				return nil, &expressionError{column, fmt.Sprintf("invalid name %q", text)}
+			}
+			kind := expressionIdentifier
+			switch {
+			case expressionKeywords[text]:
+				kind = expressionKeyword
+			case expressionWordOperators[text]:
+				kind = expressionOperator
+			}
+			tokens = append(tokens, expressionToken{kind, text, column})
+			i = end
+		default:
+			return nil, &expressionError{column, fmt.Sprintf("unexpected character %q", c)}
+		}
+	}
+
+	return append(tokens, expressionToken{kind: expressionEOF, column: len(expr) + 1}), nil
+}
+
+func isExpressionNameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
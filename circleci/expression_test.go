@@ -0,0 +1,51 @@
+package circleci
+
+import (
+	"testing"
+)
+
+func TestParseRestrictionExpression(t *testing.T) {
+	valid := []string{
+		`pipeline.git.branch == "main"`,
+		`pipeline.git.branch == 'main' and not job.ssh.enabled`,
+		`(pipeline.git.tag =~ "^v[0-9]+" or pipeline.git.branch != "dev") and pipeline.trigger_source == "webhook"`,
+		`not not (pipeline.number >= 10)`,
+		`job.ssh.enabled == false`,
+		`pipeline.config_source == "project-config"`,
+		`pipeline.git.branch starts-with "release"`,
+		`not (pipeline.git.tag starts-with 'v1.') and pipeline.git.branch == "main"`,
+	}
+
+	for _, expr := range valid {
+		if err := parseRestrictionExpression(expr); err != nil {
+			t.Errorf("Unexpected error for %s: %s", expr, err)
+		}
+	}
+}
+
+func TestParseRestrictionExpressionInvalid(t *testing.T) {
+	cases := []struct {
+		expr     string
+		expected string
+	}{
+		{expr: "", expected: "column 1: expected a value, got end of expression"},
+		{expr: `pipeline.git.branch = "main"`, expected: `column 21: unexpected character '='`},
+		{expr: `pipeline.git.branch == "main`, expected: "column 24: unterminated string"},
+		{expr: `(pipeline.git.branch == "main"`, expected: "column 31: expected ), got end of expression"},
+		{expr: `pipeline.git.branch =~ main`, expected: `column 24: expected a regular expression string after =~, got "main"`},
+		{expr: `pipeline.git.branch == "main" and`, expected: "column 34: expected a value, got end of expression"},
+		{expr: `pipeline.git.branch == "main" "dev"`, expected: `column 31: unexpected "\"dev\""`},
+		{expr: `pipeline..branch == "main"`, expected: `column 1: invalid name "pipeline..branch"`},
+		{expr: `starts-with "release"`, expected: `column 1: expected a value, got "starts-with"`},
+		{expr: `pipeline.git.branch starts-with`, expected: "column 32: expected a value, got end of expression"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.expr, func(t *testing.T) {
+			err := parseRestrictionExpression(tc.expr)
+			if err == nil || err.Error() != tc.expected {
+				t.Errorf("Error was incorrect, got: %v, want: %s.", err, tc.expected)
+			}
+		})
+	}
+}
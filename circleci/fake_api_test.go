@@ -0,0 +1,604 @@
+package circleci
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAPI is an in-memory stand-in for the parts of the CircleCI API the
+// provider uses, so resources can be tested without a CircleCI account
+type fakeAPI struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu       sync.Mutex
+	projects map[string]*fakeProject // keyed by v1.1 path, e.g. github/org/repo
+	contexts map[string]*fakeContext // keyed by id
+	orgs     []Collaboration         // organizations the user is a member of
+	requests []string                // "METHOD path" of every request received
+	nextID   int
+	clock    int // seconds since the fake epoch, advanced by every write
+
+	// untimed holds the HTTP methods whose responses about context variables
+	// leave out updated_at
+	untimed map[string]bool
+}
+
+type fakeProject struct {
+	vcsType  string
+	account  string
+	reponame string
+	followed bool
+
+	// organizationName is the spelling of the account CircleCI returns, which
+	// may differ in case from the one in requests
+	organizationName string
+	envVars          map[string]string
+
+	// forbidden holds the HTTP methods refused on the environment variables
+	forbidden map[string]bool
+}
+
+type fakeContext struct {
+	Context
+	owner        ContextOwner
+	envVars      map[string]*fakeContextEnvVar
+	restrictions []ContextRestriction
+}
+
+type fakeContextEnvVar struct {
+	ContextEnvVar
+	value string
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	f := &fakeAPI{
+		t:        t,
+		projects: map[string]*fakeProject{},
+		contexts: map[string]*fakeContext{},
+	}
+
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.server.Close)
+
+	return f
+}
+
+// client returns an ApiClient talking to the fake API
+func (f *fakeAPI) client() *ApiClient {
+	v1, _ := url.Parse(f.server.URL + "/api/v1.1/")
+	v2, _ := url.Parse(f.server.URL + "/api/v2/")
+
+	return &ApiClient{BaseURL: v1, V2BaseURL: v2, Token: "fake-token"}
+}
+
+// addProject registers a project, as if it existed in the VCS
+func (f *fakeAPI) addProject(vcsType, account, reponame string) *fakeProject {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p := &fakeProject{vcsType: vcsType, account: account, reponame: reponame, envVars: map[string]string{}}
+	f.projects[fmt.Sprintf("%s/%s/%s", vcsType, account, reponame)] = p
+
+	return p
+}
+
+// disableProject stops following a project, as if it was disabled outside of
+// Terraform
+func (f *fakeAPI) disableProject(vcsType, account, reponame string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.projects[fmt.Sprintf("%s/%s/%s", vcsType, account, reponame)].followed = false
+}
+
+// removeProject deletes a project, as if it was removed from the VCS
+func (f *fakeAPI) removeProject(vcsType, account, reponame string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.projects, fmt.Sprintf("%s/%s/%s", vcsType, account, reponame))
+}
+
+// forbidEnvVars refuses requests with the given methods to the environment
+// variables of a project, as CircleCI does for tokens lacking permissions
+func (f *fakeAPI) forbidEnvVars(vcsType, account, reponame string, methods ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p := f.projects[fmt.Sprintf("%s/%s/%s", vcsType, account, reponame)]
+	p.forbidden = map[string]bool{}
+	for _, method := range methods {
+		p.forbidden[method] = true
+	}
+}
+
+func (f *fakeAPI) envVars(vcsType, account, reponame string) map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	vars := map[string]string{}
+	for k, v := range f.projects[fmt.Sprintf("%s/%s/%s", vcsType, account, reponame)].envVars {
+		vars[k] = v
+	}
+
+	return vars
+}
+
+func (p *fakeProject) organization() string {
+	if p.organizationName != "" {
+		return p.organizationName
+	}
+
+	return p.account
+}
+
+func (f *fakeAPI) setEnvVar(vcsType, account, reponame, name, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.projects[fmt.Sprintf("%s/%s/%s", vcsType, account, reponame)].envVars[name] = value
+}
+
+// addContext registers a context, as if it was created outside of Terraform
+func (f *fakeAPI) addContext(name string, owner ContextOwner) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.newContext(name, owner).ID
+}
+
+func (f *fakeAPI) newContext(name string, owner ContextOwner) *fakeContext {
+	f.nextID++
+	c := &fakeContext{
+		Context: Context{
+			ID:        fmt.Sprintf("00000000-0000-0000-0000-%012d", f.nextID),
+			Name:      name,
+			CreatedAt: time.Date(2021, 6, 1, 12, 0, f.nextID, 0, time.UTC),
+		},
+		owner:   owner,
+		envVars: map[string]*fakeContextEnvVar{},
+	}
+	f.contexts[c.ID] = c
+
+	return c
+}
+
+// context returns the context with the given id, or nil
+func (f *fakeAPI) context(id string) *fakeContext {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.contexts[id]
+}
+
+// setContextEnvVar writes a context variable, as if it was changed outside of
+// Terraform
+func (f *fakeAPI) setContextEnvVar(contextID, name, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.contexts[contextID].setEnvVar(name, value, f.now())
+}
+
+// contextEnvVars returns the variables of a context by name
+func (f *fakeAPI) contextEnvVars(contextID string) map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	vars := map[string]string{}
+	for name, v := range f.contexts[contextID].envVars {
+		vars[name] = v.value
+	}
+
+	return vars
+}
+
+// addContextRestriction restricts a context, as if it was done outside of
+// Terraform
+func (f *fakeAPI) addContextRestriction(contextID, restrictionType, value string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.newContextRestriction(f.contexts[contextID], restrictionType, value).ID
+}
+
+func (f *fakeAPI) newContextRestriction(c *fakeContext, restrictionType, value string) ContextRestriction {
+	f.nextID++
+	restriction := ContextRestriction{
+		ID:               fmt.Sprintf("00000000-0000-0000-0000-%012d", f.nextID),
+		ContextID:        c.ID,
+		RestrictionType:  restrictionType,
+		RestrictionValue: value,
+	}
+	if restrictionType == contextRestrictionProject {
+		restriction.ProjectID = value
+		restriction.Name = "repo"
+	}
+	c.restrictions = append(c.restrictions, restriction)
+
+	return restriction
+}
+
+// contextRestrictions returns the restrictions of a context
+func (f *fakeAPI) contextRestrictions(contextID string) []ContextRestriction {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]ContextRestriction{}, f.contexts[contextID].restrictions...)
+}
+
+// now advances the fake clock and returns its time
+func (f *fakeAPI) now() time.Time {
+	f.clock++
+
+	return time.Date(2021, 6, 2, 0, 0, 0, 0, time.UTC).Add(time.Duration(f.clock) * time.Second)
+}
+
+// addOrganization makes the user a member of an organization
+func (f *fakeAPI) addOrganization(vcsType, name string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.nextID++
+	org := Collaboration{
+		ID:        fmt.Sprintf("00000000-0000-0000-0000-%012d", f.nextID),
+		VcsType:   vcsType,
+		Name:      name,
+		Slug:      fmt.Sprintf("%s/%s", map[string]string{"github": "gh", "bitbucket": "bb", "circleci": "circleci"}[vcsType], name),
+		AvatarURL: fmt.Sprintf("https://avatars.example.com/%s", name),
+	}
+	f.orgs = append(f.orgs, org)
+
+	return org.ID
+}
+
+// countRequests returns how many requests matched "METHOD path"
+func (f *fakeAPI) countRequests(request string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for _, r := range f.requests {
+		if r == request {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (f *fakeAPI) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	if r.Header.Get(tokenHeader) != "fake-token" {
+		f.reply(w, http.StatusUnauthorized, map[string]string{"message": "You must log in first."})
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/api/v1.1/"):
+		f.handleV1(w, r, strings.TrimPrefix(r.URL.Path, "/api/v1.1/"))
+	case strings.HasPrefix(r.URL.Path, "/api/v2/"):
+		f.handleV2(w, r, strings.TrimPrefix(r.URL.Path, "/api/v2/"))
+	default:
+		f.notFound(w)
+	}
+}
+
+func (f *fakeAPI) handleV1(w http.ResponseWriter, r *http.Request, path string) {
+	if path == "projects" && r.Method == "GET" {
+		projects := []Project{}
+		for _, p := range f.projects {
+			if p.followed {
+				projects = append(projects, Project{VcsType: p.vcsType, Username: p.organization(), Reponame: p.reponame})
+			}
+		}
+		f.reply(w, http.StatusOK, projects)
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts) < 5 || parts[0] != "project" {
+		f.notFound(w)
+		return
+	}
+
+	p, ok := f.projects[strings.Join(parts[1:4], "/")]
+	if !ok {
+		f.notFound(w)
+		return
+	}
+
+	action := strings.Join(parts[4:], "/")
+	if strings.HasPrefix(action, "envvar") && p.forbidden[r.Method] {
+		f.reply(w, http.StatusForbidden, map[string]string{"message": "Permission denied"})
+		return
+	}
+
+	switch {
+	case action == "follow" && r.Method == "POST":
+		p.followed = true
+		f.reply(w, http.StatusOK, map[string]interface{}{"following": true})
+	case action == "enable" && r.Method == "DELETE":
+		p.followed = false
+		f.reply(w, http.StatusOK, map[string]interface{}{})
+	case action == "envvar" && r.Method == "GET":
+		f.reply(w, http.StatusOK, p.maskedEnvVars())
+	case action == "envvar" && r.Method == "POST":
+		f.addEnvVar(w, r, p)
+	case strings.HasPrefix(action, "envvar/") && r.Method == "DELETE":
+		f.deleteEnvVar(w, p, strings.TrimPrefix(action, "envvar/"))
+	default:
+		f.notFound(w)
+	}
+}
+
+func (f *fakeAPI) handleV2(w http.ResponseWriter, r *http.Request, path string) {
+	if path == "me" && r.Method == "GET" {
+		f.reply(w, http.StatusOK, User{ID: "user-id", Login: "fake-user", Name: "Fake User"})
+		return
+	}
+
+	if path == "me/collaborations" && r.Method == "GET" {
+		f.reply(w, http.StatusOK, append([]Collaboration{}, f.orgs...))
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	if parts[0] == "context" {
+		f.handleContext(w, r, parts[1:])
+		return
+	}
+
+	if len(parts) < 4 || parts[0] != "project" {
+		f.notFound(w)
+		return
+	}
+
+	vcsType := map[string]string{"gh": "github", "bb": "bitbucket"}[parts[1]]
+	p, ok := f.projects[fmt.Sprintf("%s/%s/%s", vcsType, parts[2], parts[3])]
+	if !ok {
+		f.notFound(w)
+		return
+	}
+
+	action := strings.Join(parts[4:], "/")
+	if strings.HasPrefix(action, "envvar") && p.forbidden[r.Method] {
+		f.reply(w, http.StatusForbidden, map[string]string{"message": "Permission denied"})
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == "GET":
+		f.reply(w, http.StatusOK, ProjectV2{
+			Slug:             strings.Join(parts[1:4], "/"),
+			Name:             p.reponame,
+			OrganizationName: p.organization(),
+		})
+	case action == "envvar" && r.Method == "GET":
+		f.reply(w, http.StatusOK, map[string]interface{}{"items": p.maskedEnvVars(), "next_page_token": nil})
+	case action == "envvar" && r.Method == "POST":
+		f.addEnvVar(w, r, p)
+	case strings.HasPrefix(action, "envvar/") && r.Method == "GET":
+		name := strings.TrimPrefix(action, "envvar/")
+		value, ok := p.envVars[name]
+		if !ok {
+			f.notFound(w)
+			return
+		}
+		f.reply(w, http.StatusOK, EnvVar{Name: name, Value: maskCircleCiSecret(value)})
+	case strings.HasPrefix(action, "envvar/") && r.Method == "DELETE":
+		f.deleteEnvVar(w, p, strings.TrimPrefix(action, "envvar/"))
+	default:
+		f.notFound(w)
+	}
+}
+
+func (f *fakeAPI) handleContext(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) == 0 {
+		switch r.Method {
+		case "GET":
+			owner := ContextOwner{ID: r.URL.Query().Get("owner-id"), Slug: r.URL.Query().Get("owner-slug"), Type: r.URL.Query().Get("owner-type")}
+			items := []Context{}
+			for _, c := range f.contexts {
+				if c.owner.matches(owner) {
+					items = append(items, c.Context)
+				}
+			}
+			sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+			f.reply(w, http.StatusOK, map[string]interface{}{"items": items, "next_page_token": nil})
+		case "POST":
+			body := struct {
+				Name  string       `json:"name"`
+				Owner ContextOwner `json:"owner"`
+			}{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Name == "" {
+				f.reply(w, http.StatusBadRequest, map[string]string{"message": "invalid context"})
+				return
+			}
+			for _, c := range f.contexts {
+				if c.Name == body.Name && c.owner.matches(body.Owner) {
+					f.reply(w, http.StatusConflict, map[string]string{"message": "A context with this name already exists."})
+					return
+				}
+			}
+			f.reply(w, http.StatusOK, f.newContext(body.Name, body.Owner).Context)
+		default:
+			f.notFound(w)
+		}
+		return
+	}
+
+	c, ok := f.contexts[parts[0]]
+	if !ok {
+		f.notFound(w)
+		return
+	}
+
+	switch action := strings.Join(parts[1:], "/"); {
+	case action == "" && r.Method == "GET":
+		f.reply(w, http.StatusOK, c.Context)
+	case action == "" && r.Method == "DELETE":
+		delete(f.contexts, c.ID)
+		f.reply(w, http.StatusOK, map[string]string{"message": "Context deleted."})
+	case action == "environment-variable" && r.Method == "GET":
+		vars := []ContextEnvVar{}
+		for _, v := range c.envVars {
+			vars = append(vars, v.ContextEnvVar)
+		}
+		sort.Slice(vars, func(i, j int) bool { return vars[i].Variable < vars[j].Variable })
+		items := []interface{}{}
+		for _, v := range vars {
+			items = append(items, f.contextEnvVarReply(r.Method, v))
+		}
+		f.reply(w, http.StatusOK, map[string]interface{}{"items": items, "next_page_token": nil})
+	case strings.HasPrefix(action, "environment-variable/") && r.Method == "PUT":
+		body := struct {
+			Value string `json:"value"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			f.reply(w, http.StatusBadRequest, map[string]string{"message": "invalid environment variable"})
+			return
+		}
+		f.reply(w, http.StatusOK, f.contextEnvVarReply(r.Method, c.setEnvVar(strings.TrimPrefix(action, "environment-variable/"), body.Value, f.now())))
+	case strings.HasPrefix(action, "environment-variable/") && r.Method == "DELETE":
+		name := strings.TrimPrefix(action, "environment-variable/")
+		if _, ok := c.envVars[name]; !ok {
+			f.notFound(w)
+			return
+		}
+		delete(c.envVars, name)
+		f.reply(w, http.StatusOK, map[string]string{"message": "Environment variable deleted."})
+	case action == "restrictions" && r.Method == "GET":
+		items := append([]ContextRestriction{}, c.restrictions...)
+		f.reply(w, http.StatusOK, map[string]interface{}{"items": items, "next_page_token": nil})
+	case action == "restrictions" && r.Method == "POST":
+		body := struct {
+			RestrictionType  string `json:"restriction_type"`
+			RestrictionValue string `json:"restriction_value"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.RestrictionType == "" {
+			f.reply(w, http.StatusBadRequest, map[string]string{"message": "invalid restriction"})
+			return
+		}
+		for _, restriction := range c.restrictions {
+			if restriction.RestrictionType == body.RestrictionType && restriction.RestrictionValue == body.RestrictionValue {
+				f.reply(w, http.StatusConflict, map[string]string{"message": "Restriction already exists."})
+				return
+			}
+		}
+		f.reply(w, http.StatusCreated, f.newContextRestriction(c, body.RestrictionType, body.RestrictionValue))
+	case strings.HasPrefix(action, "restrictions/") && r.Method == "DELETE":
+		id := strings.TrimPrefix(action, "restrictions/")
+		for i, restriction := range c.restrictions {
+			if restriction.ID == id {
+				c.restrictions = append(c.restrictions[:i], c.restrictions[i+1:]...)
+				f.reply(w, http.StatusOK, map[string]string{"message": "Context restriction deleted."})
+				return
+			}
+		}
+		f.notFound(w)
+	default:
+		f.notFound(w)
+	}
+}
+
+// omitUpdatedAt leaves updated_at out of the responses about context variables
+// to requests with the given methods
+func (f *fakeAPI) omitUpdatedAt(methods ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.untimed = map[string]bool{}
+	for _, method := range methods {
+		f.untimed[method] = true
+	}
+}
+
+// contextEnvVarReply returns a context variable as the response to a request
+// with method
+func (f *fakeAPI) contextEnvVarReply(method string, v ContextEnvVar) interface{} {
+	if !f.untimed[method] {
+		return v
+	}
+
+	return map[string]interface{}{
+		"variable":   v.Variable,
+		"context_id": v.ContextID,
+		"created_at": v.CreatedAt,
+	}
+}
+
+// setEnvVar adds or replaces a variable of the context, written at now
+func (c *fakeContext) setEnvVar(name, value string, now time.Time) ContextEnvVar {
+	v, ok := c.envVars[name]
+	if !ok {
+		v = &fakeContextEnvVar{ContextEnvVar: ContextEnvVar{Variable: name, ContextID: c.ID, CreatedAt: now}}
+		c.envVars[name] = v
+	}
+	v.value = value
+	v.UpdatedAt = now
+
+	return v.ContextEnvVar
+}
+
+// matches reports whether the owner of a context is the one a request names
+func (o ContextOwner) matches(query ContextOwner) bool {
+	return (query.ID == "" || query.ID == o.ID) && (query.Slug == "" || query.Slug == o.Slug) && (query.Type == "" || o.Type == "" || query.Type == o.Type)
+}
+
+func (p *fakeProject) maskedEnvVars() []EnvVar {
+	vars := []EnvVar{}
+	for name, value := range p.envVars {
+		vars = append(vars, EnvVar{Name: name, Value: maskCircleCiSecret(value)})
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+
+	return vars
+}
+
+func (f *fakeAPI) addEnvVar(w http.ResponseWriter, r *http.Request, p *fakeProject) {
+	envVar := EnvVar{}
+	if err := json.NewDecoder(r.Body).Decode(&envVar); err != nil || envVar.Name == "" {
+		f.reply(w, http.StatusBadRequest, map[string]string{"message": "invalid environment variable"})
+		return
+	}
+
+	p.envVars[envVar.Name] = envVar.Value
+	f.reply(w, http.StatusCreated, EnvVar{Name: envVar.Name, Value: maskCircleCiSecret(envVar.Value)})
+}
+
+func (f *fakeAPI) deleteEnvVar(w http.ResponseWriter, p *fakeProject, name string) {
+	if _, ok := p.envVars[name]; !ok {
+		f.notFound(w)
+		return
+	}
+
+	delete(p.envVars, name)
+	f.reply(w, http.StatusOK, map[string]string{"message": "Environment variable deleted."})
+}
+
+func (f *fakeAPI) notFound(w http.ResponseWriter) {
+	f.reply(w, http.StatusNotFound, map[string]string{"message": "Not found."})
+}
+
+func (f *fakeAPI) reply(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		f.t.Errorf("fake API unable to encode response: %s", err)
+	}
+}
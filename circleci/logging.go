@@ -0,0 +1,145 @@
+package circleci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// logSubsystem is the tflog subsystem used for API traffic. Its level follows
+// TF_LOG / TF_LOG_PROVIDER and can be narrowed with TF_LOG_PROVIDER_CIRCLECI.
+const logSubsystem = "circleci"
+
+var (
+	// sensitiveFields are JSON keys whose values are never written to the logs
+	sensitiveFields = map[string]bool{
+		"value":       true, // environment variable values
+		"private_key": true, // checkout and additional SSH keys
+		"token":       true,
+	}
+
+	// sensitiveHeaders are HTTP headers whose values are never written to the logs
+	sensitiveHeaders = map[string]bool{
+		http.CanonicalHeaderKey(tokenHeader): true,
+		"Authorization":                      true,
+		"Cookie":                             true,
+		"Set-Cookie":                         true,
+	}
+
+	privateKeyPattern = regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`)
+)
+
+// newLogContext returns ctx with the CircleCI API logging subsystem set up
+func newLogContext(ctx context.Context) context.Context {
+	return tflog.NewSubsystem(ctx, logSubsystem, tflog.WithLevelFromEnv("TF_LOG_PROVIDER", logSubsystem))
+}
+
+func (c *ApiClient) logRequest(ctx context.Context, req *http.Request, body []byte) {
+	tflog.SubsystemDebug(ctx, logSubsystem, "sending CircleCI API request", map[string]interface{}{
+		"method":  req.Method,
+		"url":     c.redactURL(req.URL),
+		"headers": redactHeaders(req.Header),
+	})
+
+	if len(body) > 0 {
+		tflog.SubsystemTrace(ctx, logSubsystem, "CircleCI API request body", map[string]interface{}{"body": c.redact(redactBody(body))})
+	}
+}
+
+// logResponse logs the response and its body; the body is read and replaced so
+// the caller can still consume it
+func (c *ApiClient) logResponse(ctx context.Context, resp *http.Response) {
+	tflog.SubsystemDebug(ctx, logSubsystem, "received CircleCI API response", map[string]interface{}{
+		"status":  resp.StatusCode,
+		"url":     c.redactURL(resp.Request.URL),
+		"headers": redactHeaders(resp.Header),
+	})
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		tflog.SubsystemError(ctx, logSubsystem, "unable to read CircleCI API response body", map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	if len(body) > 0 {
+		tflog.SubsystemTrace(ctx, logSubsystem, "CircleCI API response body", map[string]interface{}{"body": c.redact(redactBody(body))})
+	}
+}
+
+// redactURL returns u as a string with the token query parameter masked
+func (c *ApiClient) redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+
+	redacted := *u
+	query := redacted.Query()
+	if query.Get(tokenQueryParam) != "" {
+		query.Set(tokenQueryParam, redactedValue)
+		redacted.RawQuery = query.Encode()
+	}
+
+	return c.redact(redacted.String())
+}
+
+func redactHeaders(h http.Header) map[string]string {
+	headers := make(map[string]string, len(h))
+
+	for name, values := range h {
+		if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+			headers[name] = redactedValue
+			continue
+		}
+
+		headers[name] = strings.Join(values, ", ")
+	}
+
+	return headers
+}
+
+// redactBody masks sensitive fields of a JSON body. Bodies that are not JSON are
+// returned with any PEM encoded private keys masked.
+func redactBody(body []byte) string {
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return privateKeyPattern.ReplaceAllString(string(body), redactedValue)
+	}
+
+	out, err := json.Marshal(redactValue(data))
+	if err != nil {
+		return redactedValue
+	}
+
+	return string(out)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if sensitiveFields[strings.ToLower(key)] {
+				value[key] = redactedValue
+				continue
+			}
+
+			value[key] = redactValue(field)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = redactValue(item)
+		}
+	case string:
+		return privateKeyPattern.ReplaceAllString(value, redactedValue)
+	}
+
+	return v
+}
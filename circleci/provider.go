@@ -1,8 +1,12 @@
 package circleci
 
 import (
-	"github.com/hashicorp/go-cleanhttp"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/logging"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
@@ -11,29 +15,220 @@ func Provider() *schema.Provider {
 	return &schema.Provider{
 		Schema: map[string]*schema.Schema{
 			"api_token": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				ConflictsWith: []string{"api_token_file"},
+				Description:   "Token to use to authenticate to CircleCI. Falls back to `api_token_file`, the `CIRCLECI_API_TOKEN` and `CIRCLE_TOKEN` environment variables and the CircleCI CLI configuration, in that order.",
+			},
+			"api_token_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"api_token"},
+				Description:   "Path to a file containing the token to use to authenticate to CircleCI.",
+			},
+			"skip_credentials_validation": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Skip checking the token against the `/me` endpoint when the provider is configured.",
+			},
+			"host": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("CIRCLECI_HOST", nil),
+				Description: "CircleCI host, e.g. `https://circleci.example.com` for a CircleCI server install. The API v1.1 and v2 endpoints are derived from it. Defaults to `https://circleci.com`, or the host of the CircleCI CLI configuration when the token comes from there.",
+			},
+			"api_base_url": {
 				Type:        schema.TypeString,
-				Required:    true,
-				DefaultFunc: schema.EnvDefaultFunc("CIRCLECI_API_TOKEN", nil),
-				Description: "Token to use to authenticate to CircleCI.",
+				Optional:    true,
+				Description: "Base URL of the CircleCI API holding the `v1.1/` and `v2/` endpoints, e.g. `https://circleci.example.com/api/`. Takes precedence over `host`.",
+			},
+			"runner_host": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("CIRCLECI_RUNNER_HOST", ""),
+				Description: "CircleCI runner API host. Defaults to `https://runner.circleci.com` on circleci.com and to `host` on CircleCI server.",
+			},
+			"ca_cert_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Path to a PEM encoded CA certificate bundle trusted in addition to the system roots.",
+			},
+			"ca_cert_pem": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "PEM encoded CA certificates trusted in addition to the system roots.",
+			},
+			"client_cert": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				RequiredWith: []string{"client_key"},
+				Description:  "PEM encoded client certificate for mutual TLS, or the path to a file containing it.",
+			},
+			"client_key": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Sensitive:    true,
+				RequiredWith: []string{"client_cert"},
+				Description:  "PEM encoded private key of `client_cert`, or the path to a file containing it.",
+			},
+			"insecure_skip_verify": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Skip verification of the CircleCI TLS certificate. Only meant for testing.",
+			},
+			"proxy_url": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "URL of the proxy to send requests through. Defaults to the `HTTPS_PROXY` / `NO_PROXY` environment variables.",
+			},
+			"request_timeout": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "60s",
+				ValidateFunc: validateDuration,
+				Description:  "Timeout of a single request, as a duration such as `30s` or `2m`. `0` disables the timeout.",
+			},
+			"api_token_in_query": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Send the token as the `circle-token` query parameter instead of the `Circle-Token` header. Only needed for older CircleCI server installs.",
+			},
+			"max_retries": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      3,
+				ValidateFunc: validateIntAtLeast(0),
+				Description:  "Number of times a request that failed with a rate limit, a server error or a network error is retried.",
+			},
+			"max_retry_wait_seconds": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      30,
+				ValidateFunc: validateIntAtLeast(1),
+				Description:  "Maximum number of seconds to wait between retries. Requests the server asks to delay for longer are not retried.",
+			},
+			"max_requests_per_second": {
+				Type:         schema.TypeFloat,
+				Optional:     true,
+				Default:      10,
+				ValidateFunc: validateFloatAtLeast(0),
+				Description:  "Maximum number of requests per second sent to CircleCI, shared by all resources. Set to 0 to disable the limit.",
+			},
+			"max_concurrent_requests": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      10,
+				ValidateFunc: validateIntAtLeast(0),
+				Description:  "Maximum number of requests in flight at once, shared by all resources. Set to 0 to disable the limit.",
 			},
 		},
 
-		ConfigureFunc: providerConfigure,
+		ConfigureContextFunc: providerConfigure,
 
 		ResourcesMap: map[string]*schema.Resource{
-			"circleci_project": resourceProject(),
+			"circleci_context":                      resourceContext(),
+			"circleci_context_environment_variable": resourceContextEnvironmentVariable(),
+			"circleci_context_restriction":          resourceContextRestriction(),
+			"circleci_environment_variable":         resourceEnvironmentVariable(),
+			"circleci_project":                      resourceProject(),
+			"circleci_shared_environment_variables": resourceSharedEnvironmentVariables(),
+		},
+
+		DataSourcesMap: map[string]*schema.Resource{
+			"circleci_context":      dataSourceContext(),
+			"circleci_contexts":     dataSourceContexts(),
+			"circleci_me":           dataSourceMe(),
+			"circleci_organization": dataSourceOrganization(),
 		},
 	}
 }
 
-func providerConfigure(d *schema.ResourceData) (interface{}, error) {
+func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
+	creds, err := resolveCredentials(credentialsConfig{
+		token:     d.Get("api_token").(string),
+		tokenFile: d.Get("api_token_file").(string),
+	})
+	if err != nil {
+		return nil, diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "Unable to read CircleCI credentials",
+			Detail:   err.Error(),
+		}}
+	}
+	if creds == nil {
+		return nil, diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "Missing CircleCI API token",
+			Detail:   "Set the api_token or api_token_file provider argument, the CIRCLECI_API_TOKEN or CIRCLE_TOKEN environment variable, or log in with the CircleCI CLI.",
+		}}
+	}
+
+	host := d.Get("host").(string)
+	if host == "" {
+		host = creds.host
+	}
+
+	endpoints, err := resolveEndpoints(host, d.Get("api_base_url").(string), d.Get("runner_host").(string))
+	if err != nil {
+		return nil, diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "Invalid CircleCI host",
+			Detail:   err.Error(),
+		}}
+	}
+
+	timeout, _ := time.ParseDuration(d.Get("request_timeout").(string))
+
+	httpClient, err := newHTTPClient(httpConfig{
+		caCertFile:         d.Get("ca_cert_file").(string),
+		caCertPEM:          d.Get("ca_cert_pem").(string),
+		clientCert:         d.Get("client_cert").(string),
+		clientKey:          d.Get("client_key").(string),
+		insecureSkipVerify: d.Get("insecure_skip_verify").(bool),
+		proxyURL:           d.Get("proxy_url").(string),
+		timeout:            timeout,
+	})
+	if err != nil {
+		return nil, diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "Invalid CircleCI HTTP client configuration",
+			Detail:   err.Error(),
+		}}
+	}
+
+	tflog.Debug(ctx, "Configuring CircleCI API", map[string]interface{}{"v1.1": endpoints.v1.String(), "v2": endpoints.v2.String(), "runner": endpoints.runner.String(), "server": endpoints.server})
+
 	client := &ApiClient{
-		Token:      d.Get("api_token").(string),
-		HTTPClient: cleanhttp.DefaultClient(),
-		Debug:      true,
+		BaseURL:       endpoints.v1,
+		V2BaseURL:     endpoints.v2,
+		RunnerBaseURL: endpoints.runner,
+		Server:        endpoints.server,
+		Token:         creds.token,
+		TokenInQuery:  d.Get("api_token_in_query").(bool),
+		HTTPClient:    httpClient,
+		MaxRetries:    d.Get("max_retries").(int),
+		MaxRetryWait:  time.Duration(d.Get("max_retry_wait_seconds").(int)) * time.Second,
+
+		MaxRequestsPerSecond:  d.Get("max_requests_per_second").(float64),
+		MaxConcurrentRequests: d.Get("max_concurrent_requests").(int),
 	}
 
-	client.HTTPClient.Transport = logging.NewTransport("CircleCI", client.HTTPClient.Transport)
+	if d.Get("skip_credentials_validation").(bool) {
+		return client, nil
+	}
+
+	user, err := client.V2().Me(ctx)
+	if err != nil {
+		return nil, apiErrorDiagnostics("Unable to validate the CircleCI API token", fmt.Sprintf("Checking the token from %s against %s failed", creds.source, client.v2BaseURL().Host), err, nil)
+	}
+
+	client.CurrentUser = user
+
+	tflog.Info(ctx, "Authenticated to CircleCI", map[string]interface{}{"login": user.Login, "user_id": user.ID, "token_source": creds.source})
 
 	return client, nil
 }
@@ -1,10 +1,18 @@
 package circleci
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/hashicorp/go-cty/cty"
+	ctyjson "github.com/hashicorp/go-cty/cty/json"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
 var testOrg string = os.Getenv("CIRCLECI_TEST_ORGANIZATION")
@@ -43,3 +51,166 @@ func testAccPreCheck(t *testing.T) {
 		t.Fatal("CIRCLECI_TEST_REPO must be set for acceptance tests")
 	}
 }
+
+// testApplyResource plans and applies config against state, the way Terraform
+// would, and returns the new state. It fails the test on any error diagnostic.
+func testApplyResource(t *testing.T, r *schema.Resource, state *terraform.InstanceState, config map[string]interface{}, meta interface{}) *terraform.InstanceState {
+	t.Helper()
+
+	diff := testPlanResource(t, r, state, config, meta)
+
+	// Terraform plans write-only arguments as null and passes their values in
+	// the raw configuration only
+	for k, s := range r.SchemaMap() {
+		if s.WriteOnly && diff != nil {
+			delete(diff.Attributes, k)
+		}
+	}
+
+	if diff == nil || diff.Empty() {
+		return state
+	}
+
+	newState, diags := r.Apply(context.Background(), state, diff, meta)
+	if diags.HasError() {
+		t.Fatalf("unexpected error applying: %+v", diags)
+	}
+
+	return newState
+}
+
+// testPlanResource plans config against state, passing the raw configuration
+// along the way Terraform does
+func testPlanResource(t *testing.T, r *schema.Resource, state *terraform.InstanceState, config map[string]interface{}, meta interface{}) *terraform.InstanceDiff {
+	t.Helper()
+
+	prior := &terraform.InstanceState{}
+	if state != nil {
+		prior = state.DeepCopy()
+	}
+	prior.RawConfig = testRawConfig(t, r, config)
+
+	diff, err := r.Diff(context.Background(), prior, terraform.NewResourceConfigRaw(config), meta)
+	if err != nil {
+		t.Fatalf("unexpected error planning: %s", err)
+	}
+
+	return diff
+}
+
+// testRawConfig returns config as the value Terraform sends as the raw
+// configuration, which is where write-only arguments are read from
+func testRawConfig(t *testing.T, r *schema.Resource, config map[string]interface{}) cty.Value {
+	t.Helper()
+
+	b, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("unexpected error encoding config: %s", err)
+	}
+
+	v, err := ctyjson.Unmarshal(b, r.CoreConfigSchema().ImpliedType())
+	if err != nil {
+		t.Fatalf("unexpected error converting config: %s", err)
+	}
+
+	return v
+}
+
+// testRefreshResource reads the resource and returns the refreshed state, which
+// is nil when the resource was removed
+func testRefreshResource(t *testing.T, r *schema.Resource, state *terraform.InstanceState, meta interface{}) *terraform.InstanceState {
+	t.Helper()
+
+	newState, diags := r.RefreshWithoutUpgrade(context.Background(), state, meta)
+	if diags.HasError() {
+		t.Fatalf("unexpected error refreshing: %+v", diags)
+	}
+
+	return newState
+}
+
+// testReadDataSource plans and reads a data source with config, the way
+// Terraform would, and returns its state
+func testReadDataSource(t *testing.T, r *schema.Resource, config map[string]interface{}, meta interface{}) (*terraform.InstanceState, diag.Diagnostics) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	diff, err := r.Diff(ctx, nil, terraform.NewResourceConfigRaw(config), meta)
+	if err != nil {
+		t.Fatalf("unexpected error planning: %s", err)
+	}
+
+	return r.ReadDataApply(ctx, diff, meta)
+}
+
+func TestProviderConfigureValidatesToken(t *testing.T) {
+	api := newFakeAPI(t)
+
+	configure := func(config map[string]interface{}) (*ApiClient, diag.Diagnostics) {
+		config["api_base_url"] = api.server.URL + "/api/"
+		d := schema.TestResourceDataRaw(t, Provider().Schema, config)
+
+		meta, diags := providerConfigure(context.Background(), d)
+		client, _ := meta.(*ApiClient)
+
+		return client, diags
+	}
+
+	client, diags := configure(map[string]interface{}{"api_token": "fake-token"})
+	if diags.HasError() {
+		t.Fatalf("unexpected error configuring: %+v", diags)
+	}
+	if client.CurrentUser == nil || client.CurrentUser.Login != "fake-user" {
+		t.Errorf("CurrentUser was incorrect, got: %+v.", client.CurrentUser)
+	}
+
+	_, diags = configure(map[string]interface{}{"api_token": "wrong-token"})
+	if !diags.HasError() || diags[0].Summary != "CircleCI rejected the API token" {
+		t.Errorf("Expected the token to be rejected, got: %+v.", diags)
+	}
+	if strings.Contains(fmt.Sprintf("%+v", diags), "wrong-token") {
+		t.Errorf("Diagnostics include the token: %+v", diags)
+	}
+
+	requests := api.countRequests("GET /api/v2/me")
+
+	client, diags = configure(map[string]interface{}{"api_token": "wrong-token", "skip_credentials_validation": true})
+	if diags.HasError() || client == nil || client.CurrentUser != nil {
+		t.Errorf("Expected validation to be skipped, got: %+v %+v.", client, diags)
+	}
+	if got := api.countRequests("GET /api/v2/me"); got != requests {
+		t.Errorf("Expected no request to /me when skipping validation, got %d more.", got-requests)
+	}
+}
+
+func TestProviderConfigureRunnerHost(t *testing.T) {
+	api := newFakeAPI(t)
+
+	configure := func(config map[string]interface{}) *ApiClient {
+		t.Helper()
+
+		config["api_token"] = "fake-token"
+		config["api_base_url"] = api.server.URL + "/api/"
+		d := schema.TestResourceDataRaw(t, Provider().Schema, config)
+
+		meta, diags := providerConfigure(context.Background(), d)
+		if diags.HasError() {
+			t.Fatalf("unexpected error configuring: %+v", diags)
+		}
+
+		return meta.(*ApiClient)
+	}
+
+	client := configure(map[string]interface{}{"runner_host": "runner.example.com"})
+	if got := client.RunnerBaseURL.String(); got != "https://runner.example.com/api/v3/" {
+		t.Errorf("RunnerBaseURL was incorrect, got: %s, want: https://runner.example.com/api/v3/.", got)
+	}
+
+	t.Setenv("CIRCLECI_RUNNER_HOST", "https://runner.internal")
+
+	client = configure(map[string]interface{}{})
+	if got := client.RunnerBaseURL.String(); got != "https://runner.internal/api/v3/" {
+		t.Errorf("RunnerBaseURL was incorrect, got: %s, want: https://runner.internal/api/v3/.", got)
+	}
+}
@@ -0,0 +1,101 @@
+package circleci
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// rateLimiter combines a token bucket, limiting the rate at which requests are
+// sent, with a semaphore limiting how many requests are in flight at once.
+// A single limiter is shared by every resource using the same ApiClient.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens added per second, 0 disables the token bucket
+	burst  float64 // maximum number of tokens in the bucket
+	tokens float64
+	last   time.Time
+
+	slots chan struct{} // nil when concurrency is unlimited
+}
+
+func newRateLimiter(requestsPerSecond float64, concurrency int) *rateLimiter {
+	l := &rateLimiter{}
+
+	if requestsPerSecond > 0 {
+		l.rate = requestsPerSecond
+		l.burst = math.Max(1, math.Ceil(requestsPerSecond))
+		l.tokens = l.burst
+	}
+
+	if concurrency > 0 {
+		l.slots = make(chan struct{}, concurrency)
+	}
+
+	return l
+}
+
+// acquire blocks until a request may be sent. It returns a function that must
+// be called once the request is done, and how long the caller had to wait.
+func (l *rateLimiter) acquire(ctx context.Context) (func(), time.Duration, error) {
+	start := time.Now()
+
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, time.Since(start), ctx.Err()
+		}
+	}
+
+	release := func() {
+		if l.slots != nil {
+			<-l.slots
+		}
+	}
+
+	if err := sleep(ctx, l.reserve()); err != nil {
+		l.cancelReservation()
+		release()
+		return nil, time.Since(start), err
+	}
+
+	return release, time.Since(start), nil
+}
+
+// reserve takes a token from the bucket and returns how long to wait until
+// that token is actually available
+func (l *rateLimiter) reserve() time.Duration {
+	if l.rate <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if !l.last.IsZero() && now.After(l.last) {
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// cancelReservation returns a token taken by reserve that was never used
+func (l *rateLimiter) cancelReservation() {
+	if l.rate <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens = math.Min(l.burst, l.tokens+1)
+}
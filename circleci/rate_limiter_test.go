@@ -0,0 +1,83 @@
+package circleci
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterRate(t *testing.T) {
+	l := newRateLimiter(50, 0)
+
+	start := time.Now()
+	for i := 0; i < 60; i++ {
+		release, _, err := l.acquire(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		release()
+	}
+
+	// the first 50 requests use the burst, the other 10 need 200ms worth of tokens
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Rate limiter was not limiting, 60 requests took %s.", elapsed)
+	}
+}
+
+func TestRateLimiterConcurrency(t *testing.T) {
+	l := newRateLimiter(0, 2)
+
+	var mu sync.Mutex
+	inflight, maxInflight := 0, 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			release, _, err := l.acquire(context.Background())
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+				return
+			}
+			defer release()
+
+			mu.Lock()
+			inflight++
+			if inflight > maxInflight {
+				maxInflight = inflight
+			}
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			inflight--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if maxInflight > 2 {
+		t.Errorf("Too many requests in flight, got: %d, want at most: 2.", maxInflight)
+	}
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	l := newRateLimiter(0, 1)
+
+	release, _, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, _, err := l.acquire(ctx); err == nil {
+		t.Error("expected an error while all slots are taken")
+	}
+}
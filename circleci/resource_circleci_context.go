@@ -0,0 +1,244 @@
+package circleci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+	contextOwnerOrganization = "organization"
+	contextOwnerAccount      = "account"
+)
+
+func resourceContext() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceContextCreate,
+		ReadContext:   resourceContextRead,
+		UpdateContext: resourceContextUpdate,
+		DeleteContext: resourceContextDelete,
+		CustomizeDiff: resourceContextCustomizeDiff,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceContextImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Name of the context. CircleCI can't rename contexts, so changing it replaces the context and its environment variables.",
+			},
+			"owner_id": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"owner_id", "owner_slug"},
+				Description:  "ID of the organization or account owning the context. Changing the owner replaces the context.",
+			},
+			"owner_slug": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"owner_id", "owner_slug"},
+				Description:  "Slug of the organization or account owning the context, e.g. gh/org. Changing the owner replaces the context.",
+			},
+			"owner_type": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      contextOwnerOrganization,
+				Description:  "Type of the owner, organization or account.",
+				ValidateFunc: validateContextOwnerType,
+			},
+			"created_at": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Creation time of the context, in RFC 3339 format.",
+			},
+		},
+	}
+}
+
+func resourceContextCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*ApiClient)
+
+	name := d.Get("name").(string)
+	owner := contextOwner(d)
+
+	tflog.Debug(ctx, "Creating CircleCI context", map[string]interface{}{"name": name, "owner_id": owner.ID, "owner_slug": owner.Slug})
+
+	c, err := client.V2().CreateContext(ctx, name, owner)
+	if err != nil {
+		detail := fmt.Sprintf("Unable to create CircleCI context %q", name)
+		if errors.Is(err, ErrConflict) {
+			detail += ", it may already exist and need importing"
+		}
+		return apiErrorDiagnostics("Error creating context", detail, err, nil)
+	}
+
+	d.SetId(c.ID)
+
+	return resourceContextRead(ctx, d, meta)
+}
+
+// resourceContextRead refreshes the name and creation time. The API doesn't
+// return the owner of a context, so the owner in state is kept as is.
+func resourceContextRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*ApiClient)
+
+	c, err := client.V2().GetContext(ctx, d.Id())
+	if errors.Is(err, ErrNotFound) {
+		tflog.Warn(ctx, "CircleCI context not found, removing it from state", map[string]interface{}{"id": d.Id()})
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return apiErrorDiagnostics("Error reading context", fmt.Sprintf("Unable to read CircleCI context %q", d.Id()), err, nil)
+	}
+
+	d.Set("name", c.Name)
+	d.Set("created_at", c.CreatedAt.Format(time.RFC3339))
+	if _, ok := d.GetOk("owner_type"); !ok {
+		d.Set("owner_type", contextOwnerOrganization)
+	}
+
+	return nil
+}
+
+// resourceContextCustomizeDiff replaces the context when its owner changes.
+// The API doesn't return the owner of a context, so the owner in state is the
+// one last configured or imported, as an ID or a slug. A changed owner that
+// still owns the context, e.g. the ID of the organization imported by slug, is
+// recorded without replacing the context.
+func resourceContextCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" || !d.HasChanges("owner_id", "owner_slug") {
+		return nil
+	}
+
+	if d.NewValueKnown("owner_id") && d.NewValueKnown("owner_slug") {
+		client := meta.(*ApiClient)
+
+		owner := ContextOwner{
+			ID:   d.Get("owner_id").(string),
+			Slug: d.Get("owner_slug").(string),
+			Type: d.Get("owner_type").(string),
+		}
+
+		contexts, err := client.V2().ListContexts(ctx, owner)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("unable to list the contexts of the owner of CircleCI context %q: %s", d.Id(), err)
+		}
+
+		for _, c := range contexts {
+			if c.ID == d.Id() {
+				return nil
+			}
+		}
+	}
+
+	for _, k := range []string{"owner_id", "owner_slug"} {
+		if d.HasChange(k) {
+			if err := d.ForceNew(k); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// resourceContextUpdate records an owner given in another form, which is the
+// only change that doesn't replace the context
+func resourceContextUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	return resourceContextRead(ctx, d, meta)
+}
+
+func resourceContextDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*ApiClient)
+
+	err := client.V2().DeleteContext(ctx, d.Id())
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return apiErrorDiagnostics("Error deleting context", fmt.Sprintf("Unable to delete CircleCI context %q", d.Id()), err, nil)
+	}
+
+	return nil
+}
+
+// resourceContextImport looks the context up by name. The import ID is the
+// owner and the name separated by a colon, e.g. gh/org:deploy, optionally
+// prefixed with the owner type, e.g. account:gh/user:deploy. The owner is an
+// owner ID or slug.
+func resourceContextImport(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	client := meta.(*ApiClient)
+
+	owner, name, err := parseContextImportId(d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := client.V2().FindContext(ctx, owner, name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find CircleCI context %q: %s", name, err)
+	}
+
+	d.SetId(c.ID)
+	d.Set("name", c.Name)
+	d.Set("owner_id", owner.ID)
+	d.Set("owner_slug", owner.Slug)
+	d.Set("owner_type", owner.Type)
+
+	return []*schema.ResourceData{d}, nil
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// parseContextImportId splits an import ID `[owner_type:]owner:name`
+func parseContextImportId(id string) (ContextOwner, string, error) {
+	owner := ContextOwner{Type: contextOwnerOrganization}
+
+	parts := strings.SplitN(id, ":", 2)
+	if parts[0] == contextOwnerOrganization || parts[0] == contextOwnerAccount {
+		owner.Type = parts[0]
+		if len(parts) == 2 {
+			parts = strings.SplitN(parts[1], ":", 2)
+		}
+	}
+
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return owner, "", fmt.Errorf("invalid context import id %q, expected [<owner type>:]<owner id or slug>:<name>", id)
+	}
+
+	if uuidPattern.MatchString(parts[0]) {
+		owner.ID = parts[0]
+	} else {
+		owner.Slug = parts[0]
+	}
+
+	return owner, parts[1], nil
+}
+
+func validateContextOwnerType(v interface{}, k string) (ws []string, errs []error) {
+	value := v.(string)
+	if value != contextOwnerOrganization && value != contextOwnerAccount {
+		errs = append(errs, fmt.Errorf("Value of %s must be either %s or %s.", k, contextOwnerOrganization, contextOwnerAccount))
+	}
+	return
+}
+
+// contextOwner returns the configured owner of a context
+func contextOwner(d *schema.ResourceData) ContextOwner {
+	return ContextOwner{
+		ID:   d.Get("owner_id").(string),
+		Slug: d.Get("owner_slug").(string),
+		Type: d.Get("owner_type").(string),
+	}
+}
@@ -0,0 +1,228 @@
+package circleci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func resourceContextEnvironmentVariable() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceContextEnvironmentVariableCreate,
+		ReadContext:   resourceContextEnvironmentVariableRead,
+		UpdateContext: resourceContextEnvironmentVariableUpdate,
+		DeleteContext: resourceContextEnvironmentVariableDelete,
+		CustomizeDiff: customizeDiffSecretHashes("value"),
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"context_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "ID of the context the variable belongs to.",
+			},
+			"name": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateEnvVarName,
+				Description:  "Name of the environment variable.",
+			},
+			"value": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				Sensitive:    true,
+				ExactlyOneOf: []string{"value", "value_wo"},
+				ValidateFunc: validateEnvVarValue,
+				Description:  "Value of the environment variable. Only a hash of it salted with the name is kept in state.",
+			},
+			"value_wo": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Sensitive:    true,
+				WriteOnly:    true,
+				ExactlyOneOf: []string{"value", "value_wo"},
+				ValidateFunc: validateEnvVarValue,
+				Description:  "Value of the environment variable as a write-only argument, which is never stored in the plan or state. Requires Terraform 1.11 or later.",
+			},
+			"value_wo_version": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				RequiredWith: []string{"value_wo"},
+				Description:  "Version of value_wo. Terraform can't compare write-only values, so value_wo is only written again when this changes.",
+			},
+			"created_at": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Creation time of the variable, in RFC 3339 format.",
+			},
+			"updated_at": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Time the variable was last written, in RFC 3339 format.",
+			},
+		},
+	}
+}
+
+func resourceContextEnvironmentVariableCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	contextID := d.Get("context_id").(string)
+	name := d.Get("name").(string)
+
+	tflog.Debug(ctx, "Adding environment variable to CircleCI context", map[string]interface{}{"context_id": contextID, "name": name})
+
+	if diags := setContextEnvironmentVariable(ctx, d, meta, contextID, name); diags.HasError() {
+		return diags
+	}
+
+	d.SetId(buildContextEnvironmentVariableId(contextID, name))
+
+	return resourceContextEnvironmentVariableRead(ctx, d, meta)
+}
+
+// resourceContextEnvironmentVariableRead refreshes the timestamps. CircleCI
+// never returns the value of a context variable, not even masked, so an update
+// time that differs from the one of the last write means the variable was
+// changed outside of Terraform: the value is then cleared from state, which
+// makes the next plan write it again. A write-only value is written again when
+// value_wo_version is set. An update time missing from either response is
+// unknown, and isn't compared.
+func resourceContextEnvironmentVariableRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*ApiClient)
+
+	contextID, name, err := expandContextEnvironmentVariableId(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	envVars, err := client.V2().ListContextEnvVars(ctx, contextID)
+	if errors.Is(err, ErrNotFound) {
+		tflog.Warn(ctx, "CircleCI context not found, removing environment variable from state", map[string]interface{}{"id": d.Id()})
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return apiErrorDiagnostics("Error reading context environment variable", fmt.Sprintf("Unable to list environment variables of CircleCI context %q", contextID), err, nil)
+	}
+
+	var envVar *ContextEnvVar
+	for i := range envVars {
+		if envVars[i].Variable == name {
+			envVar = &envVars[i]
+			break
+		}
+	}
+
+	if envVar == nil {
+		tflog.Warn(ctx, "CircleCI context environment variable not found, removing it from state", map[string]interface{}{"id": d.Id()})
+		d.SetId("")
+		return nil
+	}
+
+	updatedAt := formatTimestamp(envVar.UpdatedAt)
+	if written := d.Get("updated_at").(string); written != "" && updatedAt != "" && written != updatedAt {
+		tflog.Debug(ctx, "CircleCI context environment variable changed outside of Terraform", map[string]interface{}{"id": d.Id()})
+		d.Set("value", "")
+		d.Set("value_wo_version", nil)
+	}
+
+	d.Set("context_id", contextID)
+	d.Set("name", envVar.Variable)
+	d.Set("created_at", formatTimestamp(envVar.CreatedAt))
+	if updatedAt != "" {
+		d.Set("updated_at", updatedAt)
+	}
+
+	return nil
+}
+
+func resourceContextEnvironmentVariableUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	contextID, name, err := expandContextEnvironmentVariableId(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if d.HasChanges("value", "value_wo_version") {
+		if diags := setContextEnvironmentVariable(ctx, d, meta, contextID, name); diags.HasError() {
+			return diags
+		}
+	}
+
+	return resourceContextEnvironmentVariableRead(ctx, d, meta)
+}
+
+func resourceContextEnvironmentVariableDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*ApiClient)
+
+	contextID, name, err := expandContextEnvironmentVariableId(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	err = client.V2().DeleteContextEnvVar(ctx, contextID, name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return apiErrorDiagnostics("Error deleting context environment variable", fmt.Sprintf("Environment variable %q of CircleCI context %q", name, contextID), err, nil)
+	}
+
+	return nil
+}
+
+// setContextEnvironmentVariable writes the configured value and records the
+// update time CircleCI returns, which Read compares to detect rotations
+func setContextEnvironmentVariable(ctx context.Context, d *schema.ResourceData, meta interface{}, contextID, name string) diag.Diagnostics {
+	client := meta.(*ApiClient)
+
+	value, diags := getRawConfigString(d, "value")
+	if diags.HasError() {
+		return diags
+	}
+	if value == "" {
+		if value, diags = getRawConfigString(d, "value_wo"); diags.HasError() {
+			return diags
+		}
+	}
+
+	envVar, err := client.V2().SetContextEnvVar(ctx, contextID, name, value)
+	if err != nil {
+		return apiErrorDiagnostics("Error writing context environment variable", fmt.Sprintf("Environment variable %q of CircleCI context %q", name, contextID), err, nil)
+	}
+
+	d.Set("updated_at", formatTimestamp(envVar.UpdatedAt))
+
+	return nil
+}
+
+// formatTimestamp formats a time returned by CircleCI, or returns "" when the
+// response left it out
+func formatTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+// format the strings into an id `context_id:NAME`
+func buildContextEnvironmentVariableId(contextID, name string) string {
+	return fmt.Sprintf("%s:%s", contextID, name)
+}
+
+// break an id `context_id:NAME` into its parts
+func expandContextEnvironmentVariableId(id string) (string, string, error) {
+	parts := strings.SplitN(id, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid context environment variable id %q, expected <context id>:<name>", id)
+	}
+
+	return parts[0], parts[1], nil
+}
@@ -0,0 +1,190 @@
+package circleci
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestResourceContextEnvironmentVariable(t *testing.T) {
+	api := newFakeAPI(t)
+	contextID := api.addContext("deploy", ContextOwner{Slug: "gh/org", Type: "organization"})
+	api.setContextEnvVar(contextID, "UNMANAGED", "other")
+	client := api.client()
+
+	state := testApplyResource(t, resourceContextEnvironmentVariable(), nil, testContextEnvironmentVariableConfig(contextID, "aaaa1234"), client)
+
+	if state.ID != contextID+":X_FOO" {
+		t.Errorf("ID was incorrect, got: %s, want: %s:X_FOO.", state.ID, contextID)
+	}
+
+	if got, want := state.Attributes["value"], hashCircleCiSecret("X_FOO", "aaaa1234"); got != want {
+		t.Errorf("value was incorrect, got: %s, want: %s.", got, want)
+	}
+
+	if state.Attributes["created_at"] != "2021-06-02T00:00:02Z" || state.Attributes["updated_at"] != "2021-06-02T00:00:02Z" {
+		t.Errorf("Timestamps were incorrect, got: %s %s.", state.Attributes["created_at"], state.Attributes["updated_at"])
+	}
+
+	state = testApplyResource(t, resourceContextEnvironmentVariable(), state, testContextEnvironmentVariableConfig(contextID, "bbbb1234"), client)
+
+	expected := map[string]string{"UNMANAGED": "other", "X_FOO": "bbbb1234"}
+	if got := api.contextEnvVars(contextID); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Environment variables were incorrect, got: %v, want: %v.", got, expected)
+	}
+
+	if state.Attributes["updated_at"] != "2021-06-02T00:00:03Z" {
+		t.Errorf("updated_at was incorrect, got: %s.", state.Attributes["updated_at"])
+	}
+
+	state = testRefreshResource(t, resourceContextEnvironmentVariable(), state, client)
+	diff := testPlanResource(t, resourceContextEnvironmentVariable(), state, testContextEnvironmentVariableConfig(contextID, "bbbb1234"), client)
+	if diff != nil && !diff.Empty() {
+		t.Errorf("Expected an unchanged value to plan no changes, got: %v.", diff)
+	}
+
+	// a rotation outside of Terraform is drift
+	api.setContextEnvVar(contextID, "X_FOO", "cccc5678")
+	state = testRefreshResource(t, resourceContextEnvironmentVariable(), state, client)
+	testApplyResource(t, resourceContextEnvironmentVariable(), state, testContextEnvironmentVariableConfig(contextID, "bbbb1234"), client)
+
+	if got := api.contextEnvVars(contextID)["X_FOO"]; got != "bbbb1234" {
+		t.Errorf("Value was incorrect, got: %s, want: bbbb1234.", got)
+	}
+
+	if diags := resourceContextEnvironmentVariableDelete(context.Background(), resourceContextEnvironmentVariable().Data(state), client); diags.HasError() {
+		t.Fatalf("unexpected error deleting: %+v", diags)
+	}
+
+	expected = map[string]string{"UNMANAGED": "other"}
+	if got := api.contextEnvVars(contextID); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Environment variables were incorrect, got: %v, want: %v.", got, expected)
+	}
+
+	if state := testRefreshResource(t, resourceContextEnvironmentVariable(), state, client); state != nil {
+		t.Errorf("Expected the variable to be removed from state, got: %v.", state.Attributes)
+	}
+}
+
+func TestResourceContextEnvironmentVariable_noUpdateTime(t *testing.T) {
+	for _, method := range []string{"PUT", "GET"} {
+		t.Run(method, func(t *testing.T) {
+			api := newFakeAPI(t)
+			contextID := api.addContext("deploy", ContextOwner{Slug: "gh/org", Type: "organization"})
+			api.omitUpdatedAt(method)
+			client := api.client()
+
+			config := testContextEnvironmentVariableConfig(contextID, "aaaa1234")
+			state := testApplyResource(t, resourceContextEnvironmentVariable(), nil, config, client)
+
+			// a missing update time is unknown rather than a change
+			for i := 0; i < 2; i++ {
+				state = testRefreshResource(t, resourceContextEnvironmentVariable(), state, client)
+				if diff := testPlanResource(t, resourceContextEnvironmentVariable(), state, config, client); diff != nil && !diff.Empty() {
+					t.Fatalf("Expected no changes, got: %v.", diff)
+				}
+			}
+
+			if state.Attributes["updated_at"] != "2021-06-02T00:00:01Z" {
+				t.Errorf("updated_at was incorrect, got: %s.", state.Attributes["updated_at"])
+			}
+
+			// once CircleCI returns update times again, rotations are still drift
+			api.omitUpdatedAt()
+			api.setContextEnvVar(contextID, "X_FOO", "cccc5678")
+			state = testRefreshResource(t, resourceContextEnvironmentVariable(), state, client)
+			if diff := testPlanResource(t, resourceContextEnvironmentVariable(), state, config, client); diff == nil || diff.Empty() {
+				t.Error("Expected the rotated value to be written again.")
+			}
+		})
+	}
+}
+
+func TestResourceContextEnvironmentVariableImport(t *testing.T) {
+	api := newFakeAPI(t)
+	contextID := api.addContext("deploy", ContextOwner{Slug: "gh/org", Type: "organization"})
+	api.setContextEnvVar(contextID, "X_FOO", "aaaa1234")
+	client := api.client()
+
+	state := testRefreshResource(t, resourceContextEnvironmentVariable(), &terraform.InstanceState{ID: contextID + ":X_FOO"}, client)
+	if state == nil {
+		t.Fatal("Expected the imported variable to be found.")
+	}
+
+	if state.Attributes["context_id"] != contextID || state.Attributes["name"] != "X_FOO" || state.Attributes["updated_at"] != "2021-06-02T00:00:01Z" {
+		t.Errorf("Imported state was incorrect, got: %v.", state.Attributes)
+	}
+
+	// the value is unknown until written once
+	testApplyResource(t, resourceContextEnvironmentVariable(), state, testContextEnvironmentVariableConfig(contextID, "bbbb1234"), client)
+	if got := api.contextEnvVars(contextID)["X_FOO"]; got != "bbbb1234" {
+		t.Errorf("Value was incorrect, got: %s, want: bbbb1234.", got)
+	}
+
+	state = testRefreshResource(t, resourceContextEnvironmentVariable(), &terraform.InstanceState{ID: "00000000-0000-0000-0000-000000000099:X_FOO"}, client)
+	if state != nil {
+		t.Errorf("Expected a variable of a missing context to be removed from state, got: %v.", state.Attributes)
+	}
+
+	if _, _, err := expandContextEnvironmentVariableId(contextID); err == nil {
+		t.Error("Expected an error for an id without a name.")
+	}
+}
+
+func TestResourceContextEnvironmentVariableWriteOnly(t *testing.T) {
+	api := newFakeAPI(t)
+	contextID := api.addContext("deploy", ContextOwner{Slug: "gh/org", Type: "organization"})
+	client := api.client()
+
+	config := map[string]interface{}{
+		"context_id":       contextID,
+		"name":             "X_FOO",
+		"value_wo":         "aaaa1234",
+		"value_wo_version": 1,
+	}
+
+	state := testApplyResource(t, resourceContextEnvironmentVariable(), nil, config, client)
+
+	if got := api.contextEnvVars(contextID)["X_FOO"]; got != "aaaa1234" {
+		t.Errorf("Value was incorrect, got: %s, want: aaaa1234.", got)
+	}
+	for k, v := range state.Attributes {
+		if strings.Contains(v, "aaaa1234") {
+			t.Errorf("The value was stored in state as %s.", k)
+		}
+	}
+
+	// a new value is only written with a new version
+	config["value_wo"] = "bbbb1234"
+	state = testApplyResource(t, resourceContextEnvironmentVariable(), state, config, client)
+	if got := api.contextEnvVars(contextID)["X_FOO"]; got != "aaaa1234" {
+		t.Errorf("Value was incorrect, got: %s, want: aaaa1234.", got)
+	}
+
+	config["value_wo_version"] = 2
+	state = testApplyResource(t, resourceContextEnvironmentVariable(), state, config, client)
+	if got := api.contextEnvVars(contextID)["X_FOO"]; got != "bbbb1234" {
+		t.Errorf("Value was incorrect, got: %s, want: bbbb1234.", got)
+	}
+
+	// a rotation outside of Terraform is drift
+	api.setContextEnvVar(contextID, "X_FOO", "cccc5678")
+	state = testRefreshResource(t, resourceContextEnvironmentVariable(), state, client)
+	testApplyResource(t, resourceContextEnvironmentVariable(), state, config, client)
+
+	if got := api.contextEnvVars(contextID)["X_FOO"]; got != "bbbb1234" {
+		t.Errorf("Value was incorrect, got: %s, want: bbbb1234.", got)
+	}
+}
+
+// testContextEnvironmentVariableConfig returns the configuration of X_FOO on a context
+func testContextEnvironmentVariableConfig(contextID, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"context_id": contextID,
+		"name":       "X_FOO",
+		"value":      value,
+	}
+}
@@ -0,0 +1,177 @@
+package circleci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+	contextRestrictionProject    = "project"
+	contextRestrictionGroup      = "group"
+	contextRestrictionExpression = "expression"
+)
+
+func resourceContextRestriction() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceContextRestrictionCreate,
+		ReadContext:   resourceContextRestrictionRead,
+		DeleteContext: resourceContextRestrictionDelete,
+		CustomizeDiff: resourceContextRestrictionCustomizeDiff,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceContextRestrictionImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"context_id": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "ID of the restricted context.",
+			},
+			"type": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Type of the restriction, project, group or expression.",
+				ValidateFunc: func(v interface{}, k string) (ws []string, errs []error) {
+					switch v.(string) {
+					case contextRestrictionProject, contextRestrictionGroup, contextRestrictionExpression:
+					default:
+						errs = append(errs, fmt.Errorf("Value of %s must be one of %s, %s or %s.", k, contextRestrictionProject, contextRestrictionGroup, contextRestrictionExpression))
+					}
+					return
+				},
+			},
+			"value": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "ID of the project or security group allowed to use the context, or the expression pipelines must match.",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Name of the project or security group, as CircleCI shows it.",
+			},
+		},
+	}
+}
+
+// resourceContextRestrictionCustomizeDiff checks the value suits the type of
+// the restriction, so a mistyped ID or expression fails the plan rather than
+// the apply
+func resourceContextRestrictionCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.NewValueKnown("type") || !d.NewValueKnown("value") {
+		return nil
+	}
+
+	value := d.Get("value").(string)
+
+	switch d.Get("type").(string) {
+	case contextRestrictionExpression:
+		if err := parseRestrictionExpression(value); err != nil {
+			return fmt.Errorf("value: invalid expression: %s", err)
+		}
+	case contextRestrictionProject, contextRestrictionGroup:
+		if !uuidPattern.MatchString(value) {
+			return fmt.Errorf("value: %q is not a %s ID", value, d.Get("type"))
+		}
+	}
+
+	return nil
+}
+
+func resourceContextRestrictionCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*ApiClient)
+
+	diags := client.serverUnsupportedWarning("Restricting contexts")
+
+	contextID := d.Get("context_id").(string)
+	restrictionType := d.Get("type").(string)
+
+	tflog.Debug(ctx, "Restricting CircleCI context", map[string]interface{}{"context_id": contextID, "type": restrictionType})
+
+	r, err := client.V2().CreateContextRestriction(ctx, contextID, restrictionType, d.Get("value").(string))
+	if err != nil {
+		detail := fmt.Sprintf("Unable to add a %s restriction to CircleCI context %q", restrictionType, contextID)
+		if errors.Is(err, ErrConflict) {
+			detail += ", it may already exist and need importing"
+		}
+		return append(diags, apiErrorDiagnostics("Error creating context restriction", detail, err, nil)...)
+	}
+
+	d.SetId(r.ID)
+
+	return append(diags, resourceContextRestrictionRead(ctx, d, meta)...)
+}
+
+func resourceContextRestrictionRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*ApiClient)
+
+	contextID := d.Get("context_id").(string)
+
+	restrictions, err := client.V2().ListContextRestrictions(ctx, contextID)
+	if errors.Is(err, ErrNotFound) {
+		tflog.Warn(ctx, "CircleCI context not found, removing restriction from state", map[string]interface{}{"id": d.Id(), "context_id": contextID})
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return apiErrorDiagnostics("Error reading context restriction", fmt.Sprintf("Unable to list restrictions of CircleCI context %q", contextID), err, nil)
+	}
+
+	var restriction *ContextRestriction
+	for i := range restrictions {
+		if restrictions[i].ID == d.Id() {
+			restriction = &restrictions[i]
+			break
+		}
+	}
+
+	if restriction == nil {
+		tflog.Warn(ctx, "CircleCI context restriction not found, removing it from state", map[string]interface{}{"id": d.Id(), "context_id": contextID})
+		d.SetId("")
+		return nil
+	}
+
+	d.Set("type", restriction.RestrictionType)
+	d.Set("value", restriction.RestrictionValue)
+	d.Set("name", restriction.Name)
+
+	return nil
+}
+
+func resourceContextRestrictionDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*ApiClient)
+
+	contextID := d.Get("context_id").(string)
+
+	err := client.V2().DeleteContextRestriction(ctx, contextID, d.Id())
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return apiErrorDiagnostics("Error deleting context restriction", fmt.Sprintf("Unable to delete restriction %q of CircleCI context %q", d.Id(), contextID), err, nil)
+	}
+
+	return nil
+}
+
+// resourceContextRestrictionImport splits an import ID `context_id:restriction_id`
+func resourceContextRestrictionImport(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	parts := strings.SplitN(d.Id(), ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid context restriction import id %q, expected <context id>:<restriction id>", d.Id())
+	}
+
+	d.SetId(parts[1])
+	d.Set("context_id", parts[0])
+
+	return []*schema.ResourceData{d}, nil
+}
@@ -0,0 +1,135 @@
+package circleci
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestResourceContextRestriction(t *testing.T) {
+	api := newFakeAPI(t)
+	contextID := api.addContext("deploy", ContextOwner{Slug: "gh/org", Type: "organization"})
+	client := api.client()
+
+	config := map[string]interface{}{
+		"context_id": contextID,
+		"type":       "project",
+		"value":      "c6a4c37c-2f09-4b2b-9c6e-8c7d1f2f0c3a",
+	}
+
+	state := testApplyResource(t, resourceContextRestriction(), nil, config, client)
+
+	restrictions := api.contextRestrictions(contextID)
+	if len(restrictions) != 1 || restrictions[0].ID != state.ID || restrictions[0].RestrictionValue != "c6a4c37c-2f09-4b2b-9c6e-8c7d1f2f0c3a" {
+		t.Fatalf("Restrictions were incorrect, got: %+v.", restrictions)
+	}
+
+	if state.Attributes["name"] != "repo" {
+		t.Errorf("name was incorrect, got: %s.", state.Attributes["name"])
+	}
+
+	expression := testApplyResource(t, resourceContextRestriction(), nil, map[string]interface{}{
+		"context_id": contextID,
+		"type":       "expression",
+		"value":      `pipeline.git.branch == "main"`,
+	}, client)
+
+	// restrictions can't be changed in place
+	config["value"] = "d7b5d48d-3f1a-4c3c-8d7f-9d8e2f3f1d4b"
+	diff, err := resourceContextRestriction().Diff(context.Background(), state, terraform.NewResourceConfigRaw(config), client)
+	if err != nil {
+		t.Fatalf("unexpected error planning: %s", err)
+	}
+	if !diff.RequiresNew() {
+		t.Error("Expected changing the value to replace the restriction.")
+	}
+
+	if diags := resourceContextRestrictionDelete(context.Background(), resourceContextRestriction().Data(state), client); diags.HasError() {
+		t.Fatalf("unexpected error deleting: %+v", diags)
+	}
+
+	restrictions = api.contextRestrictions(contextID)
+	if len(restrictions) != 1 || restrictions[0].ID != expression.ID {
+		t.Errorf("Restrictions were incorrect, got: %+v.", restrictions)
+	}
+
+	if state := testRefreshResource(t, resourceContextRestriction(), state, client); state != nil {
+		t.Errorf("Expected the restriction to be removed from state, got: %v.", state.Attributes)
+	}
+}
+
+func TestResourceContextRestrictionPlanValidation(t *testing.T) {
+	cases := []struct {
+		name     string
+		config   map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "expression",
+			config:   map[string]interface{}{"context_id": "id", "type": "expression", "value": `pipeline.git.branch = "main"`},
+			expected: "value: invalid expression: column 21: unexpected character '='",
+		},
+		{
+			name:     "project id",
+			config:   map[string]interface{}{"context_id": "id", "type": "project", "value": "gh/org/repo"},
+			expected: `value: "gh/org/repo" is not a project ID`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := resourceContextRestriction().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(tc.config), nil)
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Error was incorrect, got: %v, want: %s.", err, tc.expected)
+			}
+		})
+	}
+
+	diags := resourceContextRestriction().Validate(terraform.NewResourceConfigRaw(map[string]interface{}{"context_id": "id", "type": "branch", "value": "main"}))
+	if !diags.HasError() {
+		t.Error("Expected an error for an unknown restriction type.")
+	}
+}
+
+func TestResourceContextRestrictionImport(t *testing.T) {
+	api := newFakeAPI(t)
+	contextID := api.addContext("deploy", ContextOwner{Slug: "gh/org", Type: "organization"})
+	id := api.addContextRestriction(contextID, "expression", `pipeline.git.branch == "main"`)
+	client := api.client()
+
+	d := resourceContextRestriction().Data(&terraform.InstanceState{ID: contextID + ":" + id})
+	imported, err := resourceContextRestrictionImport(context.Background(), d, client)
+	if err != nil {
+		t.Fatalf("unexpected error importing: %s", err)
+	}
+
+	state := testRefreshResource(t, resourceContextRestriction(), imported[0].State(), client)
+	if state == nil || state.ID != id || state.Attributes["context_id"] != contextID || state.Attributes["type"] != "expression" || state.Attributes["value"] != `pipeline.git.branch == "main"` {
+		t.Errorf("Imported restriction was incorrect, got: %v.", state)
+	}
+
+	d = resourceContextRestriction().Data(&terraform.InstanceState{ID: id})
+	if _, err := resourceContextRestrictionImport(context.Background(), d, client); err == nil {
+		t.Error("Expected an error importing without a context id.")
+	}
+}
+
+func TestResourceContextRestrictionServerWarning(t *testing.T) {
+	api := newFakeAPI(t)
+	contextID := api.addContext("deploy", ContextOwner{Slug: "gh/org", Type: "organization"})
+	client := api.client()
+	client.Server = true
+
+	d := resourceContextRestriction().Data(nil)
+	d.Set("context_id", contextID)
+	d.Set("type", "expression")
+	d.Set("value", `pipeline.git.branch == "main"`)
+
+	diags := resourceContextRestrictionCreate(context.Background(), d, client)
+	if len(diags) != 1 || diags[0].Severity != diag.Warning || diags[0].Summary != "Restricting contexts is not available on CircleCI server" {
+		t.Errorf("Diagnostics were incorrect, got: %+v.", diags)
+	}
+}
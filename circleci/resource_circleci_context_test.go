@@ -0,0 +1,163 @@
+package circleci
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestResourceContext(t *testing.T) {
+	api := newFakeAPI(t)
+	client := api.client()
+
+	config := map[string]interface{}{
+		"name":       "deploy",
+		"owner_slug": "gh/org",
+	}
+
+	state := testApplyResource(t, resourceContext(), nil, config, client)
+
+	c := api.context(state.ID)
+	if c == nil || c.Name != "deploy" || c.owner.Slug != "gh/org" || c.owner.Type != "organization" {
+		t.Fatalf("Context was incorrect, got: %+v.", c)
+	}
+
+	if state.Attributes["created_at"] != "2021-06-01T12:00:01Z" {
+		t.Errorf("created_at was incorrect, got: %s.", state.Attributes["created_at"])
+	}
+
+	// contexts can't be renamed
+	config["name"] = "deploy-production"
+	diff, err := resourceContext().Diff(context.Background(), state, terraform.NewResourceConfigRaw(config), client)
+	if err != nil {
+		t.Fatalf("unexpected error planning: %s", err)
+	}
+	if !diff.RequiresNew() {
+		t.Error("Expected renaming to replace the context.")
+	}
+
+	// a context that already exists has to be imported
+	r := resourceContext()
+	diff, err = r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(map[string]interface{}{
+		"name":       "deploy",
+		"owner_slug": "gh/org",
+	}), client)
+	if err != nil {
+		t.Fatalf("unexpected error planning: %s", err)
+	}
+	if _, diags := r.Apply(context.Background(), nil, diff, client); !diags.HasError() {
+		t.Error("Expected an error creating a context that already exists.")
+	}
+
+	if diags := resourceContextDelete(context.Background(), resourceContext().Data(state), client); diags.HasError() {
+		t.Fatalf("unexpected error deleting: %+v", diags)
+	}
+
+	if api.context(state.ID) != nil {
+		t.Error("Context was not deleted.")
+	}
+
+	if state := testRefreshResource(t, resourceContext(), state, client); state != nil {
+		t.Errorf("Expected the context to be removed from state, got: %v.", state.Attributes)
+	}
+}
+
+func TestResourceContextImport(t *testing.T) {
+	api := newFakeAPI(t)
+	id := api.addContext("deploy", ContextOwner{Slug: "gh/org", Type: "organization"})
+	api.addContext("deploy", ContextOwner{Slug: "gh/other", Type: "organization"})
+	client := api.client()
+
+	d := resourceContext().Data(&terraform.InstanceState{ID: "gh/org:deploy"})
+	imported, err := resourceContextImport(context.Background(), d, client)
+	if err != nil {
+		t.Fatalf("unexpected error importing: %s", err)
+	}
+
+	if imported[0].Id() != id || imported[0].Get("owner_slug") != "gh/org" || imported[0].Get("owner_type") != "organization" {
+		t.Errorf("Imported context was incorrect, got: %s %v.", imported[0].Id(), imported[0].State().Attributes)
+	}
+
+	d = resourceContext().Data(&terraform.InstanceState{ID: "gh/org:missing"})
+	if _, err := resourceContextImport(context.Background(), d, client); err == nil {
+		t.Error("Expected an error importing a missing context.")
+	}
+}
+
+func TestResourceContextOwnerForm(t *testing.T) {
+	api := newFakeAPI(t)
+	orgID := api.addOrganization("github", "org")
+	otherID := api.addOrganization("github", "other")
+	id := api.addContext("deploy", ContextOwner{ID: orgID, Slug: "gh/org", Type: "organization"})
+	client := api.client()
+
+	d := resourceContext().Data(&terraform.InstanceState{ID: "gh/org:deploy"})
+	imported, err := resourceContextImport(context.Background(), d, client)
+	if err != nil {
+		t.Fatalf("unexpected error importing: %s", err)
+	}
+	state := testRefreshResource(t, resourceContext(), imported[0].State(), client)
+
+	// the owner imported by slug configured by ID is the same owner
+	config := map[string]interface{}{
+		"name":     "deploy",
+		"owner_id": orgID,
+	}
+
+	diff := testPlanResource(t, resourceContext(), state, config, client)
+	if diff == nil || diff.RequiresNew() {
+		t.Fatalf("Expected the owner to be updated in place, got: %v.", diff)
+	}
+
+	state = testApplyResource(t, resourceContext(), state, config, client)
+	if state.ID != id || state.Attributes["owner_id"] != orgID || state.Attributes["owner_slug"] != "" {
+		t.Errorf("State was incorrect, got: %s %v.", state.ID, state.Attributes)
+	}
+
+	if diff := testPlanResource(t, resourceContext(), state, config, client); diff != nil && !diff.Empty() {
+		t.Errorf("Expected no changes, got: %v.", diff)
+	}
+
+	// another owner replaces the context
+	config["owner_id"] = otherID
+	if diff := testPlanResource(t, resourceContext(), state, config, client); diff == nil || !diff.RequiresNew() {
+		t.Errorf("Expected changing the owner to replace the context, got: %v.", diff)
+	}
+}
+
+func TestParseContextImportId(t *testing.T) {
+	cases := []struct {
+		id      string
+		owner   ContextOwner
+		name    string
+		invalid bool
+	}{
+		{id: "gh/org:deploy", owner: ContextOwner{Slug: "gh/org", Type: "organization"}, name: "deploy"},
+		{id: "account:gh/user:deploy:prod", owner: ContextOwner{Slug: "gh/user", Type: "account"}, name: "deploy:prod"},
+		{id: "c6a4c37c-2f09-4b2b-9c6e-8c7d1f2f0c3a:deploy", owner: ContextOwner{ID: "c6a4c37c-2f09-4b2b-9c6e-8c7d1f2f0c3a", Type: "organization"}, name: "deploy"},
+		{id: "deploy", invalid: true},
+		{id: "organization:gh/org", invalid: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.id, func(t *testing.T) {
+			owner, name, err := parseContextImportId(tc.id)
+
+			if tc.invalid {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if owner != tc.owner || name != tc.name {
+				t.Errorf("Import ID was parsed incorrectly, got: %+v %s, want: %+v %s.", owner, name, tc.owner, tc.name)
+			}
+		})
+	}
+}
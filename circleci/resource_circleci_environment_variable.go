@@ -0,0 +1,281 @@
+package circleci
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func resourceEnvironmentVariable() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceEnvironmentVariableCreate,
+		ReadContext:   resourceEnvironmentVariableRead,
+		UpdateContext: resourceEnvironmentVariableUpdate,
+		DeleteContext: resourceEnvironmentVariableDelete,
+		CustomizeDiff: customizeDiffSecretHashes("value", "value_base64"),
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"project_slug": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				Description:  "Slug of the project the variable belongs to, e.g. gh/org/repo or bitbucket/org/repo.",
+				ValidateFunc: validateProjectSlug,
+				DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
+					return equalProjectSlugs(old, new)
+				},
+			},
+			"name": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateEnvVarName,
+				Description:  "Name of the environment variable.",
+			},
+			"value": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				Sensitive:    true,
+				ExactlyOneOf: []string{"value", "value_base64", "value_wo"},
+				ValidateFunc: validateEnvVarValue,
+				Description:  "Value of the environment variable. Only a hash of it salted with the name is kept in state.",
+			},
+			"value_base64": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				Sensitive:    true,
+				ExactlyOneOf: []string{"value", "value_base64", "value_wo"},
+				Description:  "Base64 encoded value of the environment variable, written decoded. Only a hash of it salted with the name is kept in state.",
+				ValidateFunc: validateBase64Text,
+			},
+			"value_wo": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Sensitive:    true,
+				WriteOnly:    true,
+				ExactlyOneOf: []string{"value", "value_base64", "value_wo"},
+				ValidateFunc: validateEnvVarValue,
+				Description:  "Value of the environment variable as a write-only argument, which is never stored in the plan or state. Requires Terraform 1.11 or later.",
+			},
+			"value_wo_version": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				RequiredWith: []string{"value_wo"},
+				Description:  "Version of value_wo. Terraform can't compare write-only values, so value_wo is only written again when this changes.",
+			},
+			"masked_value": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Value as CircleCI shows it, e.g. xxxx1234.",
+			},
+		},
+	}
+}
+
+func resourceEnvironmentVariableCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*ApiClient)
+
+	vcstype, account, reponame, err := expandProjectSlug(d.Get("project_slug").(string))
+	if err != nil {
+		return diag.Diagnostics{{
+			Severity:      diag.Error,
+			Summary:       "Invalid project slug",
+			Detail:        err.Error(),
+			AttributePath: cty.GetAttrPath("project_slug"),
+		}}
+	}
+
+	name := d.Get("name").(string)
+
+	tflog.Debug(ctx, "Adding environment variable to CircleCI project", map[string]interface{}{"project_slug": d.Get("project_slug"), "name": name})
+
+	value, diags := environmentVariableValue(d)
+	if diags.HasError() {
+		return diags
+	}
+
+	envVar, err := client.AddEnvVar(ctx, vcstype, account, reponame, name, value)
+	if err != nil {
+		return apiErrorDiagnostics("Error adding environment variable", fmt.Sprintf("Environment variable %q", name), err, nil)
+	}
+
+	d.SetId(buildEnvironmentVariableId(vcstype, account, reponame, name))
+	d.Set("masked_value", envVar.Value)
+
+	return resourceEnvironmentVariableRead(ctx, d, meta)
+}
+
+// resourceEnvironmentVariableRead refreshes the masked value. CircleCI never
+// returns the value itself, so a masked value that differs from the one last
+// written means the variable was changed outside of Terraform: the value is
+// then cleared from state, which makes the next plan write it again. A
+// write-only value is written again when value_wo_version is set.
+func resourceEnvironmentVariableRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*ApiClient)
+
+	vcstype, account, reponame, name, err := expandEnvironmentVariableId(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	envVars, err := client.ListEnvVars(ctx, vcstype, account, reponame)
+	if errors.Is(err, ErrNotFound) {
+		tflog.Warn(ctx, "CircleCI project not found, removing environment variable from state", map[string]interface{}{"id": d.Id()})
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return apiErrorDiagnostics("Error reading environment variable", fmt.Sprintf("Unable to list environment variables of CircleCI project %s/%s/%s", vcstype, account, reponame), err, nil)
+	}
+
+	var envVar *EnvVar
+	for i := range envVars {
+		if envVars[i].Name == name {
+			envVar = &envVars[i]
+			break
+		}
+	}
+
+	if envVar == nil {
+		tflog.Warn(ctx, "CircleCI environment variable not found, removing it from state", map[string]interface{}{"id": d.Id()})
+		d.SetId("")
+		return nil
+	}
+
+	if masked := d.Get("masked_value").(string); masked != "" && masked != envVar.Value {
+		tflog.Debug(ctx, "CircleCI environment variable changed outside of Terraform", map[string]interface{}{"id": d.Id()})
+		d.Set("value", "")
+		d.Set("value_base64", "")
+		d.Set("value_wo_version", nil)
+	}
+
+	if slug := d.Get("project_slug").(string); !equalProjectSlugs(slug, ProjectSlug(vcstype, account, reponame)) {
+		d.Set("project_slug", ProjectSlug(vcstype, account, reponame))
+	}
+	d.Set("name", envVar.Name)
+	d.Set("masked_value", envVar.Value)
+
+	return nil
+}
+
+func resourceEnvironmentVariableUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*ApiClient)
+
+	vcstype, account, reponame, name, err := expandEnvironmentVariableId(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if d.HasChanges("value", "value_base64", "value_wo_version") {
+		value, diags := environmentVariableValue(d)
+		if diags.HasError() {
+			return diags
+		}
+
+		envVar, err := client.AddEnvVar(ctx, vcstype, account, reponame, name, value)
+		if err != nil {
+			return apiErrorDiagnostics("Error updating environment variable", fmt.Sprintf("Environment variable %q", name), err, nil)
+		}
+
+		d.Set("masked_value", envVar.Value)
+	}
+
+	return resourceEnvironmentVariableRead(ctx, d, meta)
+}
+
+func resourceEnvironmentVariableDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*ApiClient)
+
+	vcstype, account, reponame, name, err := expandEnvironmentVariableId(d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	err = client.DeleteEnvVar(ctx, vcstype, account, reponame, name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return apiErrorDiagnostics("Error deleting environment variable", fmt.Sprintf("Environment variable %q", name), err, nil)
+	}
+
+	return nil
+}
+
+// environmentVariableValue returns the value to write, decoding value_base64
+// when it is used
+func environmentVariableValue(d *schema.ResourceData) (string, diag.Diagnostics) {
+	v, diags := getRawConfigString(d, "value_base64")
+	if diags.HasError() {
+		return "", diags
+	}
+	if v != "" {
+		// validated by validateBase64Text
+		b, _ := base64.StdEncoding.DecodeString(v)
+		return string(b), nil
+	}
+
+	if v, diags = getRawConfigString(d, "value"); diags.HasError() || v != "" {
+		return v, diags
+	}
+
+	return getRawConfigString(d, "value_wo")
+}
+
+// expandProjectSlug splits a project slug into the vcs type, account and
+// repository names the v1.1 API uses
+func expandProjectSlug(slug string) (string, string, string, error) {
+	vcs, org, project, err := parseProjectSlug(slug)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	switch vcs {
+	case "gh":
+		vcs = "github"
+	case "bb":
+		vcs = "bitbucket"
+	}
+
+	return vcs, org, project, nil
+}
+
+// equalProjectSlugs reports whether two slugs name the same project, e.g.
+// gh/org/repo and github/org/repo
+func equalProjectSlugs(a, b string) bool {
+	va, oa, pa, err := parseProjectSlug(a)
+	if err != nil {
+		return a == b
+	}
+
+	vb, ob, pb, err := parseProjectSlug(b)
+	if err != nil {
+		return a == b
+	}
+
+	return va == vb && oa == ob && pa == pb
+}
+
+// format the strings into an id `vcs:account:repo:NAME`
+func buildEnvironmentVariableId(vcstype, account, reponame, name string) string {
+	return fmt.Sprintf("%s:%s:%s:%s", vcstype, account, reponame, name)
+}
+
+// break an id `vcs:account:repo:NAME` into its parts
+func expandEnvironmentVariableId(id string) (string, string, string, string, error) {
+	parts := strings.SplitN(id, ":", 4)
+	if len(parts) != 4 || parts[0] == "" || parts[1] == "" || parts[2] == "" || parts[3] == "" {
+		return "", "", "", "", fmt.Errorf("invalid environment variable id %q, expected <vcs>:<account>:<repo>:<name>", id)
+	}
+
+	return parts[0], parts[1], parts[2], parts[3], nil
+}
@@ -0,0 +1,196 @@
+package circleci
+
+import (
+	"context"
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestResourceEnvironmentVariable(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addProject("github", "org", "repo")
+	api.setEnvVar("github", "org", "repo", "UNMANAGED", "other")
+	client := api.client()
+
+	state := testApplyResource(t, resourceEnvironmentVariable(), nil, testEnvironmentVariableConfig("aaaa1234"), client)
+
+	if state.ID != "github:org:repo:X_FOO" {
+		t.Errorf("ID was incorrect, got: %s, want: github:org:repo:X_FOO.", state.ID)
+	}
+
+	if got, want := state.Attributes["value"], hashCircleCiSecret("X_FOO", "aaaa1234"); got != want {
+		t.Errorf("value was incorrect, got: %s, want: %s.", got, want)
+	}
+
+	// a new value with the same masked value is still a change, planned as
+	// its hash
+	diff := testPlanResource(t, resourceEnvironmentVariable(), state, testEnvironmentVariableConfig("bbbb1234"), client)
+	if attr := diff.Attributes["value"]; attr == nil || attr.New != hashCircleCiSecret("X_FOO", "bbbb1234") {
+		t.Errorf("Planned value was incorrect, got: %+v.", attr)
+	}
+
+	state = testApplyResource(t, resourceEnvironmentVariable(), state, testEnvironmentVariableConfig("bbbb1234"), client)
+
+	expected := map[string]string{"UNMANAGED": "other", "X_FOO": "bbbb1234"}
+	if got := api.envVars("github", "org", "repo"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Environment variables were incorrect, got: %v, want: %v.", got, expected)
+	}
+
+	diff = testPlanResource(t, resourceEnvironmentVariable(), state, testEnvironmentVariableConfig("bbbb1234"), client)
+	if diff != nil && !diff.Empty() {
+		t.Errorf("Expected an unchanged value to plan no changes, got: %v.", diff)
+	}
+
+	// a change outside of Terraform is drift
+	api.setEnvVar("github", "org", "repo", "X_FOO", "cccc5678")
+	state = testRefreshResource(t, resourceEnvironmentVariable(), state, client)
+	testApplyResource(t, resourceEnvironmentVariable(), state, testEnvironmentVariableConfig("bbbb1234"), client)
+
+	if got := api.envVars("github", "org", "repo")["X_FOO"]; got != "bbbb1234" {
+		t.Errorf("Value was incorrect, got: %s, want: bbbb1234.", got)
+	}
+
+	if diags := resourceEnvironmentVariableDelete(context.Background(), resourceEnvironmentVariable().Data(state), client); diags.HasError() {
+		t.Fatalf("unexpected error deleting: %+v", diags)
+	}
+
+	expected = map[string]string{"UNMANAGED": "other"}
+	if got := api.envVars("github", "org", "repo"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Environment variables were incorrect, got: %v, want: %v.", got, expected)
+	}
+}
+
+func TestResourceEnvironmentVariableImport(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addProject("github", "org", "repo")
+	api.setEnvVar("github", "org", "repo", "X_FOO", "aaaa1234")
+	client := api.client()
+
+	state := testRefreshResource(t, resourceEnvironmentVariable(), &terraform.InstanceState{ID: "github:org:repo:X_FOO"}, client)
+	if state == nil {
+		t.Fatal("Expected the imported variable to be found.")
+	}
+
+	if state.Attributes["project_slug"] != "gh/org/repo" || state.Attributes["name"] != "X_FOO" || state.Attributes["masked_value"] != "xxxx1234" {
+		t.Errorf("Imported state was incorrect, got: %v.", state.Attributes)
+	}
+
+	state = testRefreshResource(t, resourceEnvironmentVariable(), &terraform.InstanceState{ID: "github:org:repo:MISSING"}, client)
+	if state != nil {
+		t.Errorf("Expected a missing variable to be removed from state, got: %v.", state.Attributes)
+	}
+}
+
+func TestResourceEnvironmentVariableBase64(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addProject("github", "org", "repo")
+	client := api.client()
+
+	config := map[string]interface{}{
+		"project_slug": "gh/org/repo",
+		"name":         "CERTIFICATE",
+		"value_base64": base64.StdEncoding.EncodeToString([]byte("-----BEGIN CERTIFICATE-----\nMIIB\n")),
+	}
+
+	state := testApplyResource(t, resourceEnvironmentVariable(), nil, config, client)
+
+	if got := api.envVars("github", "org", "repo")["CERTIFICATE"]; got != "-----BEGIN CERTIFICATE-----\nMIIB\n" {
+		t.Errorf("Value was incorrect, got: %q.", got)
+	}
+
+	if got, want := state.Attributes["value_base64"], hashCircleCiSecret("CERTIFICATE", config["value_base64"].(string)); got != want {
+		t.Errorf("value_base64 was incorrect, got: %s, want: %s.", got, want)
+	}
+
+	// switching to value clears the hash of value_base64
+	delete(config, "value_base64")
+	config["value"] = "-----BEGIN CERTIFICATE-----\nMIIC\n"
+	state = testApplyResource(t, resourceEnvironmentVariable(), state, config, client)
+
+	if got := api.envVars("github", "org", "repo")["CERTIFICATE"]; got != "-----BEGIN CERTIFICATE-----\nMIIC\n" {
+		t.Errorf("Value was incorrect, got: %q.", got)
+	}
+	if state.Attributes["value_base64"] != "" {
+		t.Errorf("value_base64 was incorrect, got: %s.", state.Attributes["value_base64"])
+	}
+
+	for _, value := range []string{"not base64!", base64.StdEncoding.EncodeToString([]byte{0xff, 0xfe, 0x00})} {
+		if _, errs := validateBase64Text(value, "value_base64"); len(errs) == 0 {
+			t.Errorf("Expected an error for %q.", value)
+		}
+	}
+}
+
+func TestResourceEnvironmentVariableWriteOnly(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addProject("github", "org", "repo")
+	client := api.client()
+
+	config := map[string]interface{}{
+		"project_slug":     "gh/org/repo",
+		"name":             "X_FOO",
+		"value_wo":         "aaaa1234",
+		"value_wo_version": 1,
+	}
+
+	state := testApplyResource(t, resourceEnvironmentVariable(), nil, config, client)
+
+	if got := api.envVars("github", "org", "repo")["X_FOO"]; got != "aaaa1234" {
+		t.Errorf("Value was incorrect, got: %s, want: aaaa1234.", got)
+	}
+	for k, v := range state.Attributes {
+		if strings.Contains(v, "aaaa1234") {
+			t.Errorf("The value was stored in state as %s.", k)
+		}
+	}
+
+	// a new value is only written with a new version
+	config["value_wo"] = "bbbb1234"
+	state = testApplyResource(t, resourceEnvironmentVariable(), state, config, client)
+	if got := api.envVars("github", "org", "repo")["X_FOO"]; got != "aaaa1234" {
+		t.Errorf("Value was incorrect, got: %s, want: aaaa1234.", got)
+	}
+
+	config["value_wo_version"] = 2
+	state = testApplyResource(t, resourceEnvironmentVariable(), state, config, client)
+	if got := api.envVars("github", "org", "repo")["X_FOO"]; got != "bbbb1234" {
+		t.Errorf("Value was incorrect, got: %s, want: bbbb1234.", got)
+	}
+
+	// a change outside of Terraform is drift
+	api.setEnvVar("github", "org", "repo", "X_FOO", "cccc5678")
+	state = testRefreshResource(t, resourceEnvironmentVariable(), state, client)
+	testApplyResource(t, resourceEnvironmentVariable(), state, config, client)
+
+	if got := api.envVars("github", "org", "repo")["X_FOO"]; got != "bbbb1234" {
+		t.Errorf("Value was incorrect, got: %s, want: bbbb1234.", got)
+	}
+}
+
+func TestExpandEnvironmentVariableId(t *testing.T) {
+	vcstype, account, reponame, name, err := expandEnvironmentVariableId("bitbucket:org:repo:X_FOO")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if vcstype != "bitbucket" || account != "org" || reponame != "repo" || name != "X_FOO" {
+		t.Errorf("ID parts were incorrect, got: %s %s %s %s.", vcstype, account, reponame, name)
+	}
+
+	if _, _, _, _, err := expandEnvironmentVariableId("github:org:repo"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+// testEnvironmentVariableConfig returns the configuration of X_FOO on github/org/repo
+func testEnvironmentVariableConfig(value string) map[string]interface{} {
+	return map[string]interface{}{
+		"project_slug": "github/org/repo",
+		"name":         "X_FOO",
+		"value":        value,
+	}
+}
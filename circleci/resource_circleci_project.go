@@ -1,22 +1,44 @@
 package circleci
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"hash/crc32"
-	"log"
+	"regexp"
+	"sort"
 	"strings"
 
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
+const (
+	variablesModeAuthoritative = "authoritative"
+	variablesModeAdditive      = "additive"
+)
+
 func resourceProject() *schema.Resource {
 	return &schema.Resource{
-		Create: resourceProjectCreate,
-		Read:   resourceProjectRead,
-		Update: resourceProjectUpdate,
-		Delete: resourceProjectDelete,
+		CreateContext: resourceProjectCreate,
+		ReadContext:   resourceProjectRead,
+		UpdateContext: resourceProjectUpdate,
+		DeleteContext: resourceProjectDelete,
 		Importer: &schema.ResourceImporter{
-			State: schema.ImportStatePassthrough,
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+
+		CustomizeDiff: resourceProjectCustomizeDiff,
+
+		SchemaVersion: 1,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Version: 0,
+				Type:    resourceProjectV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: resourceProjectStateUpgradeV0,
+			},
 		},
 
 		Schema: map[string]*schema.Schema{
@@ -47,160 +69,553 @@ func resourceProject() *schema.Resource {
 				},
 			},
 			"variable": {
-				Type:     schema.TypeSet,
-				Optional: true,
+				Type:          schema.TypeSet,
+				Optional:      true,
+				Deprecated:    "Use the variables map instead.",
+				ConflictsWith: []string{"variables"},
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 						"name": {
-							Type:     schema.TypeString,
-							Required: true,
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validateEnvVarName,
 						},
 						"value": {
-							Type:     schema.TypeString,
-							Required: true,
+							Type:         schema.TypeString,
+							Required:     true,
+							Sensitive:    true,
+							ValidateFunc: validateEnvVarValue,
 							StateFunc: func(v interface{}) string {
 								return maskCircleCiSecret(v.(string))
 							},
 						},
+						"value_sha256": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "Salted SHA-256 of the value last written by Terraform, used to detect changes the masked value hides.",
+						},
 					},
 				},
 				Set: variableHash,
 			},
+			"variables": {
+				Type:             schema.TypeMap,
+				Optional:         true,
+				Sensitive:        true,
+				Elem:             &schema.Schema{Type: schema.TypeString},
+				ConflictsWith:    []string{"variable"},
+				Description:      "Environment variables of the project, keyed by name.",
+				ValidateFunc:     validateEnvVarMap,
+				DiffSuppressFunc: suppressUnchangedVariable,
+			},
+			"variables_sha256": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Salted SHA-256 of the values in variables last written by Terraform, keyed by name.",
+			},
+			"variables_dotenv": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				Description: "Environment variables of the project in dotenv format. Only a salted hash of it is kept in state.",
+				StateFunc: func(v interface{}) string {
+					return hashCircleCiSecret("", v.(string))
+				},
+				ValidateFunc: validateDotenv,
+			},
+			"variables_dotenv_masked": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Masked values of the environment variables loaded from variables_dotenv, keyed by name.",
+			},
+			"variables_mode": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     variablesModeAuthoritative,
+				Description: "How variables not declared in the configuration are handled: authoritative deletes them, additive leaves them alone.",
+				ValidateFunc: func(v interface{}, k string) (ws []string, errs []error) {
+					value := v.(string)
+					if value != variablesModeAuthoritative && value != variablesModeAdditive {
+						errs = append(errs, fmt.Errorf("Value of %s must be either %s or %s.", k, variablesModeAuthoritative, variablesModeAdditive))
+					}
+					return
+				},
+			},
+			"managed_prefix": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "In authoritative mode, only undeclared variables whose name starts with this prefix are deleted.",
+			},
 		},
 	}
 }
 
-func resourceProjectCreate(d *schema.ResourceData, meta interface{}) error {
+func resourceProjectCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	client := meta.(*ApiClient)
 
 	vcstype := d.Get("vcs_type").(string)
 	account := d.Get("account").(string)
 	reponame := d.Get("project").(string)
 
-	log.Printf("[DEBUG] Following %s/%s %s project on CircleCI", account, reponame, vcstype)
+	tflog.Debug(ctx, "Following project on CircleCI", map[string]interface{}{"vcs_type": vcstype, "account": account, "project": reponame})
 
-	_, err := client.FollowProject(vcstype, account, reponame)
+	_, err := client.FollowProject(ctx, vcstype, account, reponame)
 	if err != nil {
-		return fmt.Errorf("error following project: %s", err)
+		return apiErrorDiagnostics("Error following project", fmt.Sprintf("Unable to follow CircleCI project %s/%s/%s", vcstype, account, reponame), err, nil)
 	}
 
 	d.SetId(buildId(vcstype, account, reponame))
 
-	return resourceProjectUpdate(d, meta)
+	return resourceProjectUpdate(ctx, d, meta)
 }
 
-func resourceProjectRead(d *schema.ResourceData, meta interface{}) error {
+func resourceProjectRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	client := meta.(*ApiClient)
 
 	vcstype, account, reponame := expandId(d.Id())
 
-	project, err := client.GetProject(vcstype, account, reponame)
-	if err != nil {
+	project, err := client.GetProject(ctx, vcstype, account, reponame)
+	if errors.Is(err, ErrNotFound) {
+		tflog.Warn(ctx, "CircleCI project not found, removing it from state", map[string]interface{}{"id": d.Id()})
 		d.SetId("")
-		return fmt.Errorf("Error reading CircleCI project %q: %s", d.Id(), err)
+		return nil
+	}
+	if err != nil {
+		return apiErrorDiagnostics("Error reading project", fmt.Sprintf("Unable to read CircleCI project %q", d.Id()), err, nil)
 	}
 
 	d.Set("vcs_type", project.VcsType)
-	d.Set("account", project.Username)
-	d.Set("project", project.Reponame)
+	// CircleCI matches names case-insensitively, and a spelling that only
+	// differs in case mustn't plan a replacement
+	d.Set("account", keepSpelling(account, project.Username))
+	d.Set("project", keepSpelling(reponame, project.Reponame))
+	if _, ok := d.GetOk("variables_mode"); !ok {
+		d.Set("variables_mode", variablesModeAuthoritative)
+	}
 
-	envVars, err := client.ListEnvVars(vcstype, account, reponame)
+	envVars, err := client.ListEnvVars(ctx, vcstype, account, reponame)
+	if errors.Is(err, ErrNotFound) {
+		tflog.Warn(ctx, "CircleCI project not found, removing it from state", map[string]interface{}{"id": d.Id()})
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return apiErrorDiagnostics("Error reading environment variables", fmt.Sprintf("Unable to list environment variables of CircleCI project %q", d.Id()), err, cty.GetAttrPath(variablesAttribute(d)))
+	}
 
 	if err := flattenEnvironmentVariables(d, envVars); err != nil {
-		return fmt.Errorf("Error setting environment: %v", err)
+		return diag.Diagnostics{{
+			Severity:      diag.Error,
+			Summary:       "Error setting environment variables",
+			Detail:        err.Error(),
+			AttributePath: cty.GetAttrPath(variablesAttribute(d)),
+		}}
 	}
 
 	return nil
 }
 
-func resourceProjectUpdate(d *schema.ResourceData, meta interface{}) error {
+func resourceProjectUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	client := meta.(*ApiClient)
 
 	vcstype, account, reponame := expandId(d.Id())
 
 	d.Partial(true)
 
-	if d.HasChange("variable") {
-		o, n := d.GetChange("variable")
-
-		if o == nil {
-			o = new(schema.Set)
-		}
-		if n == nil {
-			n = new(schema.Set)
+	if d.HasChanges("variable", "variables", "variables_dotenv") {
+		o, n, err := projectVariables(d)
+		if err != nil {
+			return diag.Diagnostics{{
+				Severity:      diag.Error,
+				Summary:       "Invalid variables_dotenv",
+				Detail:        err.Error(),
+				AttributePath: cty.GetAttrPath("variables_dotenv"),
+			}}
 		}
 
-		os := o.(*schema.Set)
-		ns := n.(*schema.Set)
-
-		for _, pRaw := range ns.Difference(os).List() {
-			data := pRaw.(map[string]interface{})
+		upserts, deletes := diffEnvironmentVariables(o, n)
 
+		for _, v := range upserts {
 			_, err := client.AddEnvVar(
+				ctx,
 				vcstype,
 				account,
 				reponame,
-				data["name"].(string),
-				data["value"].(string),
+				v.Name,
+				v.Value,
 			)
 
 			if err != nil {
-				return err
+				return apiErrorDiagnostics("Error adding environment variable", fmt.Sprintf("Environment variable %q", v.Name), err, cty.GetAttrPath(variablesAttribute(d)))
 			}
 		}
 
-		for _, pRaw := range os.Difference(ns).List() {
-			data := pRaw.(map[string]interface{})
-
+		for _, name := range deletes {
 			err := client.DeleteEnvVar(
+				ctx,
 				vcstype,
 				account,
 				reponame,
-				data["name"].(string),
+				name,
 			)
 
-			if err != nil {
+			if err != nil && !errors.Is(err, ErrNotFound) {
 				d.Partial(true)
-				return err
+				return apiErrorDiagnostics("Error deleting environment variable", fmt.Sprintf("Environment variable %q", name), err, cty.GetAttrPath(variablesAttribute(d)))
 			}
 		}
 	}
 
 	d.Partial(false)
 
-	return resourceProjectRead(d, meta)
+	return resourceProjectRead(ctx, d, meta)
 }
 
-func resourceProjectDelete(d *schema.ResourceData, meta interface{}) error {
+func resourceProjectDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	client := meta.(*ApiClient)
 
 	vcstype, account, reponame := expandId(d.Id())
 
-	err := client.DisableProject(vcstype, account, reponame)
+	err := client.DisableProject(ctx, vcstype, account, reponame)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
 	if err != nil {
-		return fmt.Errorf("Error disabling project %q: %s", d.Id(), err)
+		return apiErrorDiagnostics("Error disabling project", fmt.Sprintf("Unable to disable CircleCI project %q", d.Id()), err, nil)
 	}
 
 	return nil
 }
 
+// diffEnvironmentVariables compares the old and new variable sets by name.
+// Variables that are new or whose value changed are returned as upserts, since
+// adding a variable overwrites an existing one; only names that are gone from
+// the new set are deleted.
+func diffEnvironmentVariables(o, n *schema.Set) ([]EnvVar, []string) {
+	newNames := make(map[string]bool)
+	for _, raw := range n.List() {
+		newNames[raw.(map[string]interface{})["name"].(string)] = true
+	}
+
+	upserts := []EnvVar{}
+	for _, raw := range n.Difference(o).List() {
+		data := raw.(map[string]interface{})
+		upserts = append(upserts, EnvVar{Name: data["name"].(string), Value: data["value"].(string)})
+	}
+	sort.Slice(upserts, func(i, j int) bool { return upserts[i].Name < upserts[j].Name })
+
+	deletes := []string{}
+	for _, raw := range o.Difference(n).List() {
+		name := raw.(map[string]interface{})["name"].(string)
+		if !newNames[name] {
+			deletes = append(deletes, name)
+		}
+	}
+	sort.Strings(deletes)
+
+	return upserts, deletes
+}
+
+// resourceProjectCustomizeDiff checks the project identifiers match the syntax
+// of the vcs type, and that no variable is declared twice, whether in variable
+// blocks, variables or variables_dotenv
+func resourceProjectCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if d.NewValueKnown("vcs_type") && d.NewValueKnown("account") && d.NewValueKnown("project") {
+		if err := checkProjectIdentifiers(d.Get("vcs_type").(string), d.Get("account").(string), d.Get("project").(string)); err != nil {
+			return err
+		}
+	}
+
+	dotenv := d.Get("variables_dotenv_masked").(map[string]interface{})
+
+	if o, n := d.GetChange("variables_dotenv"); dotenvChanged(o.(string), n.(string)) {
+		vars, err := parseDotenv(n.(string))
+		if err != nil {
+			return fmt.Errorf("variables_dotenv: %s", err)
+		}
+
+		dotenv = make(map[string]interface{}, len(vars))
+		for name, value := range vars {
+			dotenv[name] = value
+		}
+
+		if err := d.SetNewComputed("variables_dotenv_masked"); err != nil {
+			return err
+		}
+	}
+
+	declared := make(map[string]bool)
+	for name := range d.Get("variables").(map[string]interface{}) {
+		declared[name] = true
+	}
+	for _, raw := range d.Get("variable").(*schema.Set).List() {
+		name := raw.(map[string]interface{})["name"].(string)
+		if declared[name] {
+			return fmt.Errorf("variable %s is declared more than once", name)
+		}
+		declared[name] = true
+	}
+
+	names := make([]string, 0, len(dotenv))
+	for name := range dotenv {
+		if declared[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if len(names) > 0 {
+		return fmt.Errorf("variables %s are declared both in variables_dotenv and in variables", strings.Join(names, ", "))
+	}
+
+	return nil
+}
+
+// projectNamePatterns are the account and repository names each vcs type allows
+var projectNamePatterns = map[string]struct{ account, repo *regexp.Regexp }{
+	"github": {
+		account: regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]{0,37}[A-Za-z0-9])?$`),
+		repo:    regexp.MustCompile(`^[A-Za-z0-9._-]{1,100}$`),
+	},
+	"bitbucket": {
+		account: regexp.MustCompile(`^[A-Za-z0-9_-]{1,62}$`),
+		repo:    regexp.MustCompile(`^[A-Za-z0-9._-]{1,62}$`),
+	},
+}
+
+func checkProjectIdentifiers(vcstype, account, reponame string) error {
+	patterns, ok := projectNamePatterns[vcstype]
+	if !ok {
+		return nil
+	}
+
+	if !patterns.account.MatchString(account) {
+		return fmt.Errorf("account %q is not a valid %s account name", account, vcstype)
+	}
+
+	if !patterns.repo.MatchString(reponame) || reponame == "." || reponame == ".." {
+		return fmt.Errorf("project %q is not a valid %s repository name", reponame, vcstype)
+	}
+
+	return nil
+}
+
+// dotenvChanged compares the hash of variables_dotenv in state with the
+// configured content
+func dotenvChanged(hash, content string) bool {
+	if hash == "" && content == "" {
+		return false
+	}
+
+	return hash != hashCircleCiSecret("", content)
+}
+
+// projectVariables returns the old and new variables of the project, whether
+// they are declared as variable blocks or in the variables map, in the form of
+// variable blocks
+func projectVariables(d *schema.ResourceData) (*schema.Set, *schema.Set, error) {
+	oSet, nSet := d.GetChange("variable")
+	oMap, nMap := d.GetChange("variables")
+
+	// unchanged values of the map are masked, so they take the hash recorded
+	// in state
+	hashes, _ := d.GetChange("variables_sha256")
+
+	oDotenv, nDotenv, err := dotenvVariables(d)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	o := oSet.(*schema.Set).Union(variablesSet(oMap.(map[string]interface{}), hashes.(map[string]interface{}))).Union(oDotenv)
+	n := nSet.(*schema.Set).Union(variablesSet(nMap.(map[string]interface{}), hashes.(map[string]interface{}))).Union(nDotenv)
+
+	return o, n, nil
+}
+
+// dotenvVariables returns the old and new variables of variables_dotenv. Only
+// the masked values of the old ones are known; the content is only known when
+// it changed, otherwise state holds its hash and the variables are unchanged.
+func dotenvVariables(d *schema.ResourceData) (*schema.Set, *schema.Set, error) {
+	masked, _ := d.GetChange("variables_dotenv_masked")
+
+	o := variablesSet(masked.(map[string]interface{}), nil)
+	if !d.HasChange("variables_dotenv") {
+		return o, o, nil
+	}
+
+	vars, err := parseDotenv(d.Get("variables_dotenv").(string))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	n := schema.NewSet(variableHash, nil)
+	for name, value := range vars {
+		n.Add(map[string]interface{}{"name": name, "value": value, "value_sha256": ""})
+	}
+
+	return o, n, nil
+}
+
+// variablesSet converts the variables map to a set of variable blocks
+func variablesSet(variables, hashes map[string]interface{}) *schema.Set {
+	s := schema.NewSet(variableHash, nil)
+
+	for name, value := range variables {
+		sum, _ := hashes[name].(string)
+		s.Add(map[string]interface{}{
+			"name":         name,
+			"value":        value.(string),
+			"value_sha256": sum,
+		})
+	}
+
+	return s
+}
+
+// variablesAttribute returns the attribute the variables of the project are
+// kept in: the deprecated variable blocks when they are used, otherwise the
+// variables map
+func variablesAttribute(d *schema.ResourceData) string {
+	if d.Get("variable").(*schema.Set).Len() > 0 {
+		return "variable"
+	}
+
+	return "variables"
+}
+
+// flattenEnvironmentVariables sets the variables read from CircleCI. The API
+// only returns masked values, so the hash of the value Terraform last wrote is
+// carried over while the masked value still matches it; when it no longer
+// does, the variable was changed outside of Terraform and the hash is dropped
+// so the next plan shows the drift.
+//
+// Variables that aren't declared are only kept when the project is managed
+// authoritatively, and match managed_prefix if set, so the next plan deletes
+// them.
+//
+// Variables of variables_dotenv only have their masked value recorded. When it
+// changes, the content is dropped from state so the next plan writes it again.
 func flattenEnvironmentVariables(d *schema.ResourceData, vars []EnvVar) error {
+	_, declared, err := projectVariables(d)
+	if err != nil {
+		return err
+	}
+
+	_, dotenv, err := dotenvVariables(d)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]map[string]interface{})
+	for _, raw := range declared.List() {
+		data := raw.(map[string]interface{})
+		known[data["name"].(string)] = data
+	}
+
+	dotenvMasked := make(map[string]interface{})
+	dotenvDrift := false
+	for _, raw := range dotenv.List() {
+		data := raw.(map[string]interface{})
+		dotenvMasked[data["name"].(string)] = nil
+	}
+
+	additive := d.Get("variables_mode").(string) == variablesModeAdditive
+	prefix := d.Get("managed_prefix").(string)
+
 	variables := make([]map[string]interface{}, 0, len(vars))
 
 	for _, v := range vars {
+		if _, ok := dotenvMasked[v.Name]; ok {
+			masked, _ := variableValueHash(known[v.Name])
+			dotenvDrift = dotenvDrift || masked != v.Value
+			dotenvMasked[v.Name] = v.Value
+			continue
+		}
+
+		if _, ok := known[v.Name]; !ok && (additive || !strings.HasPrefix(v.Name, prefix)) {
+			continue
+		}
+
 		variable := make(map[string]interface{})
 
 		variable["name"] = v.Name
 		variable["value"] = v.Value
+		variable["value_sha256"] = ""
+
+		if data, ok := known[v.Name]; ok {
+			masked, sum := variableValueHash(data)
+			if masked == v.Value {
+				variable["value_sha256"] = sum
+			}
+		}
 
 		variables = append(variables, variable)
 	}
 
-	if err := d.Set("variable", variables); err != nil {
+	for name, masked := range dotenvMasked {
+		if masked == nil {
+			dotenvDrift = true
+			delete(dotenvMasked, name)
+		}
+	}
+
+	if dotenvDrift {
+		d.Set("variables_dotenv", "")
+	}
+
+	if err := d.Set("variables_dotenv_masked", dotenvMasked); err != nil {
 		return err
 	}
 
-	return nil
+	if variablesAttribute(d) == "variable" {
+		if err := d.Set("variable", variables); err != nil {
+			return err
+		}
+
+		return d.Set("variables_sha256", map[string]interface{}{})
+	}
+
+	values := make(map[string]interface{}, len(variables))
+	hashes := make(map[string]interface{}, len(variables))
+	for _, variable := range variables {
+		name := variable["name"].(string)
+		values[name] = variable["value"]
+		if sum := variable["value_sha256"].(string); sum != "" {
+			hashes[name] = sum
+		}
+	}
+
+	if err := d.Set("variables", values); err != nil {
+		return err
+	}
+
+	return d.Set("variables_sha256", hashes)
+}
+
+// suppressUnchangedVariable hides the difference between a value of the
+// variables map and the masked value in state when the hash recorded for it
+// still matches
+func suppressUnchangedVariable(k, old, new string, d *schema.ResourceData) bool {
+	name := strings.TrimPrefix(k, "variables.")
+	if name == "%" {
+		return false
+	}
+
+	sum, _ := d.Get("variables_sha256").(map[string]interface{})[name].(string)
+
+	return sum != "" && sum == hashCircleCiSecret(name, new)
+}
+
+// keepSpelling returns name when CircleCI spells it the same but for case,
+// and CircleCI's spelling otherwise
+func keepSpelling(name, circleci string) string {
+	if strings.EqualFold(name, circleci) {
+		return name
+	}
+
+	return circleci
 }
 
 // format the strings into an id `a:b:c`
@@ -214,21 +629,44 @@ func expandId(id string) (string, string, string) {
 	return parts[0], parts[1], parts[2]
 }
 
+// variableHash identifies a variable by its name and the hash of its value, so
+// any change of a configured value is a change of the set even when the masked
+// value stays the same. Variables read back without a hash, because they were
+// changed outside of Terraform, fall back to their masked value.
 func variableHash(v interface{}) int {
 	m := v.(map[string]interface{})
 
 	name := m["name"].(string)
-	value := m["value"].(string)
+	masked, sum := variableValueHash(m)
 
-	if !strings.HasPrefix(value, "xxxx") {
-		value = maskCircleCiSecret(value)
+	if sum == "" {
+		return hashcodeString(fmt.Sprintf("%s:%s", name, masked))
 	}
 
 	return hashcodeString(
-		fmt.Sprintf("%s:%s", name, value),
+		fmt.Sprintf("%s:%s", name, sum),
 	)
 }
 
+// variableValueHash returns the masked value of a variable and the hash of its
+// value: the one recorded in state, or one computed from the configured value.
+// The hash is empty when only the masked value is known.
+func variableValueHash(m map[string]interface{}) (string, string) {
+	name := m["name"].(string)
+	value := m["value"].(string)
+
+	sum, _ := m["value_sha256"].(string)
+	if sum == unknownValue {
+		sum = ""
+	}
+
+	if strings.HasPrefix(value, "xxxx") {
+		return value, sum
+	}
+
+	return maskCircleCiSecret(value), hashCircleCiSecret(name, value)
+}
+
 // String hashes a string to a unique hashcode.
 //
 // crc32 returns a uint32, but for our use we need
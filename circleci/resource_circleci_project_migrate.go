@@ -0,0 +1,89 @@
+package circleci
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// resourceProjectV0 is the schema of circleci_project before variables could
+// be declared as a map
+func resourceProjectV0() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"account": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "This is the GitHub or Bitbucket project account (organization) name for the target project (not your personal GitHub or Bitbucket username).",
+			},
+			"project": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "This is the GitHub or Bitbucket project (repository) name.",
+			},
+			"vcs_type": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Default:     "github",
+				Description: "Version control system type your project uses.",
+				ValidateFunc: func(v interface{}, k string) (ws []string, errs []error) {
+					value := v.(string)
+					if value != "github" && value != "bitbucket" {
+						errs = append(errs, fmt.Errorf("Value of vcs_type must be either github or bitbucket."))
+					}
+					return
+				},
+			},
+			"variable": {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"value": {
+							Type:     schema.TypeString,
+							Required: true,
+							StateFunc: func(v interface{}) string {
+								return maskCircleCiSecret(v.(string))
+							},
+						},
+					},
+				},
+				Set: variableHash,
+			},
+		},
+	}
+}
+
+// resourceProjectStateUpgradeV0 moves the variable blocks of the state to the
+// variables map. Version 0 kept no hash of the values, so the next apply
+// writes them once more, and moves them back to variable blocks if the
+// configuration still declares them.
+func resourceProjectStateUpgradeV0(ctx context.Context, rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	variables := map[string]interface{}{}
+
+	raw, _ := rawState["variable"].([]interface{})
+	for _, v := range raw {
+		variable, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		name, _ := variable["name"].(string)
+		value, _ := variable["value"].(string)
+		variables[name] = value
+	}
+
+	rawState["variable"] = []interface{}{}
+	rawState["variables"] = variables
+	rawState["variables_sha256"] = map[string]interface{}{}
+
+	return rawState, nil
+}
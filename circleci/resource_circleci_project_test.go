@@ -1,11 +1,16 @@
 package circleci
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"reflect"
 	"testing"
 
+	ctyjson "github.com/hashicorp/go-cty/cty/json"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
@@ -27,8 +32,8 @@ func TestAccCircleCIProject_basic(t *testing.T) {
 					resource.TestCheckResourceAttr("circleci_project.project", "vcs_type", "github"),
 					resource.TestCheckResourceAttr("circleci_project.project", "account", org),
 					resource.TestCheckResourceAttr("circleci_project.project", "project", repo),
-					resource.TestCheckResourceAttr("circleci_project.project", "variable.3244239841.name", "__________X_FOO"),
-					resource.TestCheckResourceAttr("circleci_project.project", "variable.3244239841.value", "xxxxr"),
+					resource.TestCheckResourceAttr("circleci_project.project", "variables.%", "1"),
+					resource.TestCheckResourceAttr("circleci_project.project", "variables.__________X_FOO", "xxxxr"),
 					testAccCheckCircleCiProjectAttributes(&proj, &testAccCircleCIProjectExpectedAttributes{}),
 				),
 			},
@@ -39,12 +44,10 @@ func TestAccCircleCIProject_basic(t *testing.T) {
 					resource.TestCheckResourceAttr("circleci_project.project", "vcs_type", "github"),
 					resource.TestCheckResourceAttr("circleci_project.project", "account", org),
 					resource.TestCheckResourceAttr("circleci_project.project", "project", repo),
-					resource.TestCheckResourceAttr("circleci_project.project", "variable.1444073175.name", "RENAMED_X_FOO"),
-					resource.TestCheckResourceAttr("circleci_project.project", "variable.1444073175.value", "xxxxr"),
-					resource.TestCheckResourceAttr("circleci_project.project", "variable.3732134584.name", "X_FIZZ"),
-					resource.TestCheckResourceAttr("circleci_project.project", "variable.3732134584.value", "xxxxzz"),
-					resource.TestCheckNoResourceAttr("circleci_project.project", "variable.3244239841.name"),
-					resource.TestCheckNoResourceAttr("circleci_project.project", "variable.3244239841.value"),
+					resource.TestCheckResourceAttr("circleci_project.project", "variables.%", "2"),
+					resource.TestCheckResourceAttr("circleci_project.project", "variables.RENAMED_X_FOO", "xxxxr"),
+					resource.TestCheckResourceAttr("circleci_project.project", "variables.X_FIZZ", "xxxxzz"),
+					resource.TestCheckNoResourceAttr("circleci_project.project", "variables.__________X_FOO"),
 					testAccCheckCircleCiProjectAttributes(&proj, &testAccCircleCIProjectExpectedAttributes{}),
 				),
 			},
@@ -52,6 +55,606 @@ func TestAccCircleCIProject_basic(t *testing.T) {
 	})
 }
 
+// TestResourceProjectVariableSetKeys checks the set keys variable blocks are
+// stored under, which plans and existing state refer to
+func TestResourceProjectVariableSetKeys(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addProject("github", "org", "repo")
+	client := api.client()
+
+	state := testApplyResource(t, resourceProject(), nil, testProjectConfig(map[string]string{"__________X_FOO": "bar"}), client)
+	if state.Attributes["variable.895310199.name"] != "__________X_FOO" {
+		t.Errorf("Set key was incorrect, got: %v.", state.Attributes)
+	}
+
+	state = testApplyResource(t, resourceProject(), state, testProjectConfig(map[string]string{"RENAMED_X_FOO": "bar", "X_FIZZ": "buzz"}), client)
+	if state.Attributes["variable.2154552645.name"] != "RENAMED_X_FOO" || state.Attributes["variable.2017741867.name"] != "X_FIZZ" {
+		t.Errorf("Set keys were incorrect, got: %v.", state.Attributes)
+	}
+}
+
+func TestResourceProjectUpdate_valueChange(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addProject("github", "org", "repo")
+	client := api.client()
+
+	state := testApplyResource(t, resourceProject(), nil, testProjectConfig(map[string]string{
+		"X_FOO":  "bar",
+		"X_FIZZ": "buzz",
+	}), client)
+
+	state = testApplyResource(t, resourceProject(), state, testProjectConfig(map[string]string{
+		"X_FOO":  "baz",
+		"X_FIZZ": "buzz",
+	}), client)
+
+	expected := map[string]string{"X_FOO": "baz", "X_FIZZ": "buzz"}
+	if got := api.envVars("github", "org", "repo"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Environment variables were incorrect, got: %v, want: %v.", got, expected)
+	}
+
+	if n := api.countRequests("DELETE /api/v1.1/project/github/org/repo/envvar/X_FOO"); n != 0 {
+		t.Errorf("Changing a value deleted the variable %d times.", n)
+	}
+
+	if state.Attributes["variable.#"] != "2" {
+		t.Errorf("Number of variables in state was incorrect, got: %s, want: 2.", state.Attributes["variable.#"])
+	}
+}
+
+func TestResourceProjectUpdate_sameMaskedValue(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addProject("github", "org", "repo")
+	client := api.client()
+
+	state := testApplyResource(t, resourceProject(), nil, testProjectConfig(map[string]string{
+		"X_FOO": "aaaa1234",
+	}), client)
+
+	diff, err := resourceProject().Diff(context.Background(), state, terraform.NewResourceConfigRaw(testProjectConfig(map[string]string{
+		"X_FOO": "aaaa1234",
+	})), client)
+	if err != nil {
+		t.Fatalf("unexpected error planning: %s", err)
+	}
+	if diff != nil && !diff.Empty() {
+		t.Errorf("Expected an unchanged value to plan no changes, got: %v.", diff)
+	}
+
+	testApplyResource(t, resourceProject(), state, testProjectConfig(map[string]string{
+		"X_FOO": "bbbb1234",
+	}), client)
+
+	expected := map[string]string{"X_FOO": "bbbb1234"}
+	if got := api.envVars("github", "org", "repo"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Environment variables were incorrect, got: %v, want: %v.", got, expected)
+	}
+}
+
+func TestResourceProjectRead_drift(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addProject("github", "org", "repo")
+	client := api.client()
+
+	config := testProjectConfig(map[string]string{"X_FOO": "aaaa1234"})
+	state := testApplyResource(t, resourceProject(), nil, config, client)
+
+	// an edit that keeps the masked value can't be seen, one that changes it can
+	api.setEnvVar("github", "org", "repo", "X_FOO", "cccc1234")
+	state = testRefreshResource(t, resourceProject(), state, client)
+
+	diff, err := resourceProject().Diff(context.Background(), state, terraform.NewResourceConfigRaw(config), client)
+	if err != nil {
+		t.Fatalf("unexpected error planning: %s", err)
+	}
+	if diff != nil && !diff.Empty() {
+		t.Errorf("Expected no changes for an undetectable edit, got: %v.", diff)
+	}
+
+	api.setEnvVar("github", "org", "repo", "X_FOO", "cccc5678")
+	state = testRefreshResource(t, resourceProject(), state, client)
+
+	testApplyResource(t, resourceProject(), state, config, client)
+
+	expected := map[string]string{"X_FOO": "aaaa1234"}
+	if got := api.envVars("github", "org", "repo"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Environment variables were incorrect, got: %v, want: %v.", got, expected)
+	}
+}
+
+func TestResourceProjectVariables(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addProject("github", "org", "repo")
+	client := api.client()
+
+	config := func(vars map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"vcs_type":  "github",
+			"account":   "org",
+			"project":   "repo",
+			"variables": vars,
+		}
+	}
+
+	state := testApplyResource(t, resourceProject(), nil, config(map[string]interface{}{
+		"X_FOO":  "aaaa1234",
+		"X_FIZZ": "buzz",
+	}), client)
+
+	if state.Attributes["variables.X_FOO"] != "xxxx1234" {
+		t.Errorf("Value in state was incorrect, got: %s, want: xxxx1234.", state.Attributes["variables.X_FOO"])
+	}
+
+	diff, err := resourceProject().Diff(context.Background(), state, terraform.NewResourceConfigRaw(config(map[string]interface{}{
+		"X_FOO":  "bbbb1234",
+		"X_FIZZ": "buzz",
+	})), client)
+	if err != nil {
+		t.Fatalf("unexpected error planning: %s", err)
+	}
+	if diff == nil || len(diff.Attributes) != 1 || diff.Attributes["variables.X_FOO"] == nil {
+		t.Fatalf("Expected a change of variables.X_FOO only, got: %v.", diff)
+	}
+
+	state = testApplyResource(t, resourceProject(), state, config(map[string]interface{}{
+		"X_FOO": "bbbb1234",
+	}), client)
+
+	expected := map[string]string{"X_FOO": "bbbb1234"}
+	if got := api.envVars("github", "org", "repo"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Environment variables were incorrect, got: %v, want: %v.", got, expected)
+	}
+
+	diff, err = resourceProject().Diff(context.Background(), state, terraform.NewResourceConfigRaw(config(map[string]interface{}{
+		"X_FOO": "bbbb1234",
+	})), client)
+	if err != nil {
+		t.Fatalf("unexpected error planning: %s", err)
+	}
+	if diff != nil && !diff.Empty() {
+		t.Errorf("Expected an unchanged value to plan no changes, got: %v.", diff)
+	}
+
+	api.setEnvVar("github", "org", "repo", "X_FOO", "cccc5678")
+	state = testRefreshResource(t, resourceProject(), state, client)
+	testApplyResource(t, resourceProject(), state, config(map[string]interface{}{
+		"X_FOO": "bbbb1234",
+	}), client)
+
+	if got := api.envVars("github", "org", "repo"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Environment variables were incorrect, got: %v, want: %v.", got, expected)
+	}
+}
+
+func TestResourceProjectVariablesDotenv(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addProject("github", "org", "repo")
+	client := api.client()
+
+	config := func(dotenv string) map[string]interface{} {
+		return map[string]interface{}{
+			"vcs_type":         "github",
+			"account":          "org",
+			"project":          "repo",
+			"variables":        map[string]interface{}{"X_FOO": "bar"},
+			"variables_dotenv": dotenv,
+		}
+	}
+
+	state := testApplyResource(t, resourceProject(), nil, config("export USER=deploy\nPASSWORD='aaaa1234'\n"), client)
+
+	expected := map[string]string{"X_FOO": "bar", "USER": "deploy", "PASSWORD": "aaaa1234"}
+	if got := api.envVars("github", "org", "repo"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Environment variables were incorrect, got: %v, want: %v.", got, expected)
+	}
+
+	if state.Attributes["variables_dotenv_masked.PASSWORD"] != "xxxx1234" || state.Attributes["variables.%"] != "1" {
+		t.Errorf("State was incorrect, got: %v.", state.Attributes)
+	}
+
+	diff, err := resourceProject().Diff(context.Background(), state, terraform.NewResourceConfigRaw(config("export USER=deploy\nPASSWORD='aaaa1234'\n")), client)
+	if err != nil {
+		t.Fatalf("unexpected error planning: %s", err)
+	}
+	if diff != nil && !diff.Empty() {
+		t.Errorf("Expected unchanged content to plan no changes, got: %v.", diff)
+	}
+
+	state = testApplyResource(t, resourceProject(), state, config("PASSWORD=bbbb1234\n"), client)
+
+	expected = map[string]string{"X_FOO": "bar", "PASSWORD": "bbbb1234"}
+	if got := api.envVars("github", "org", "repo"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Environment variables were incorrect, got: %v, want: %v.", got, expected)
+	}
+
+	api.setEnvVar("github", "org", "repo", "PASSWORD", "cccc5678")
+	state = testRefreshResource(t, resourceProject(), state, client)
+	testApplyResource(t, resourceProject(), state, config("PASSWORD=bbbb1234\n"), client)
+
+	if got := api.envVars("github", "org", "repo"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Environment variables were incorrect, got: %v, want: %v.", got, expected)
+	}
+
+	_, err = resourceProject().Diff(context.Background(), state, terraform.NewResourceConfigRaw(config("X_FOO=baz\n")), client)
+	if err == nil {
+		t.Error("Expected an error for a variable declared twice.")
+	}
+}
+
+func TestResourceProjectStateUpgradeV0(t *testing.T) {
+	cases := []struct {
+		name   string
+		config map[string]interface{}
+		check  func(attrs map[string]string) bool
+	}{
+		{
+			name:   "variable blocks",
+			config: testProjectConfig(map[string]string{"X_FOO": "bar"}),
+			check: func(attrs map[string]string) bool {
+				key := "variable." + variableHashKey("X_FOO", "bar")
+				return attrs["variables.%"] == "0" && attrs[key+".value"] == "xxxxr" && attrs[key+".value_sha256"] == hashCircleCiSecret("X_FOO", "bar")
+			},
+		},
+		{
+			name: "variables",
+			config: map[string]interface{}{
+				"vcs_type":  "github",
+				"account":   "org",
+				"project":   "repo",
+				"variables": map[string]interface{}{"X_FOO": "bar"},
+			},
+			check: func(attrs map[string]string) bool {
+				return attrs["variable.#"] == "0" && attrs["variables.X_FOO"] == "xxxxr" && attrs["variables_sha256.X_FOO"] == hashCircleCiSecret("X_FOO", "bar")
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			api := newFakeAPI(t)
+			api.addProject("github", "org", "repo").followed = true
+			api.setEnvVar("github", "org", "repo", "X_FOO", "bar")
+			client := api.client()
+
+			// state as version 0 of the provider wrote it
+			v0 := []byte(`{
+				"id": "github:org:repo",
+				"vcs_type": "github",
+				"account": "org",
+				"project": "repo",
+				"variable": [{"name": "X_FOO", "value": "xxxxr"}]
+			}`)
+			if _, err := ctyjson.Unmarshal(v0, resourceProjectV0().CoreConfigSchema().ImpliedType()); err != nil {
+				t.Fatalf("unexpected error decoding version 0 state: %s", err)
+			}
+
+			var rawState map[string]interface{}
+			if err := json.Unmarshal(v0, &rawState); err != nil {
+				t.Fatalf("unexpected error decoding state: %s", err)
+			}
+
+			upgraded, err := resourceProjectStateUpgradeV0(context.Background(), rawState, client)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			expected := map[string]interface{}{"X_FOO": "xxxxr"}
+			if !reflect.DeepEqual(upgraded["variables"], expected) {
+				t.Errorf("Variables were incorrect, got: %v, want: %v.", upgraded["variables"], expected)
+			}
+
+			state := testRefreshResource(t, resourceProject(), testUpgradedState(t, resourceProject(), upgraded), client)
+			if state.Attributes["variables.X_FOO"] != "xxxxr" {
+				t.Errorf("Refreshed state was incorrect, got: %v.", state.Attributes)
+			}
+
+			// without a hash, the values are written once more
+			diff := testPlanResource(t, resourceProject(), state, tc.config, client)
+			if diff == nil || diff.Empty() || diff.RequiresNew() {
+				t.Fatalf("Expected an in-place update, got: %v.", diff)
+			}
+
+			state = testApplyResource(t, resourceProject(), state, tc.config, client)
+			if !tc.check(state.Attributes) {
+				t.Errorf("Variables in state were incorrect, got: %v.", state.Attributes)
+			}
+
+			if n := api.countRequests("POST /api/v1.1/project/github/org/repo/envvar"); n != 1 {
+				t.Errorf("Variables were written %d times, want: 1.", n)
+			}
+
+			state = testRefreshResource(t, resourceProject(), state, client)
+			if diff := testPlanResource(t, resourceProject(), state, tc.config, client); diff != nil && !diff.Empty() {
+				t.Errorf("Expected no changes after the apply, got: %v.", diff)
+			}
+		})
+	}
+}
+
+// testUpgradedState converts state returned by a state upgrader into instance
+// state, the way Terraform does before refreshing it
+func testUpgradedState(t *testing.T, r *schema.Resource, rawState map[string]interface{}) *terraform.InstanceState {
+	t.Helper()
+
+	b, err := json.Marshal(rawState)
+	if err != nil {
+		t.Fatalf("unexpected error encoding state: %s", err)
+	}
+
+	v, err := ctyjson.Unmarshal(b, r.CoreConfigSchema().ImpliedType())
+	if err != nil {
+		t.Fatalf("unexpected error converting state: %s", err)
+	}
+
+	state, err := r.ShimInstanceStateFromValue(v)
+	if err != nil {
+		t.Fatalf("unexpected error converting state: %s", err)
+	}
+
+	return state
+}
+
+func TestResourceProjectVariablesMode(t *testing.T) {
+	cases := []struct {
+		name     string
+		mode     string
+		prefix   string
+		expected map[string]string
+	}{
+		{
+			name:     "authoritative",
+			mode:     "authoritative",
+			expected: map[string]string{"X_FOO": "bar"},
+		},
+		{
+			name:     "authoritative with prefix",
+			mode:     "authoritative",
+			prefix:   "TF_",
+			expected: map[string]string{"X_FOO": "bar", "OTHER": "other"},
+		},
+		{
+			name:     "additive",
+			mode:     "additive",
+			prefix:   "TF_",
+			expected: map[string]string{"X_FOO": "bar", "OTHER": "other", "TF_OTHER": "other"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			api := newFakeAPI(t)
+			api.addProject("github", "org", "repo")
+			client := api.client()
+
+			config := testProjectConfig(map[string]string{"X_FOO": "bar"})
+			config["variables_mode"] = tc.mode
+			config["managed_prefix"] = tc.prefix
+
+			state := testApplyResource(t, resourceProject(), nil, config, client)
+
+			api.setEnvVar("github", "org", "repo", "OTHER", "other")
+			api.setEnvVar("github", "org", "repo", "TF_OTHER", "other")
+
+			state = testRefreshResource(t, resourceProject(), state, client)
+			testApplyResource(t, resourceProject(), state, config, client)
+
+			if got := api.envVars("github", "org", "repo"); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("Environment variables were incorrect, got: %v, want: %v.", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestResourceProjectUpdate_rename(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addProject("github", "org", "repo")
+	client := api.client()
+
+	state := testApplyResource(t, resourceProject(), nil, testProjectConfig(map[string]string{
+		"X_FOO": "bar",
+	}), client)
+
+	testApplyResource(t, resourceProject(), state, testProjectConfig(map[string]string{
+		"RENAMED_X_FOO": "bar",
+		"X_FIZZ":        "buzz",
+	}), client)
+
+	expected := map[string]string{"RENAMED_X_FOO": "bar", "X_FIZZ": "buzz"}
+	if got := api.envVars("github", "org", "repo"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Environment variables were incorrect, got: %v, want: %v.", got, expected)
+	}
+}
+
+func TestResourceProjectRead_projectGone(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addProject("github", "org", "repo")
+	client := api.client()
+
+	state := testApplyResource(t, resourceProject(), nil, testProjectConfig(nil), client)
+
+	api.removeProject("github", "org", "repo")
+
+	if state := testRefreshResource(t, resourceProject(), state, client); state != nil {
+		t.Errorf("Expected the project to be removed from state, got: %v.", state.Attributes)
+	}
+}
+
+func TestResourceProjectRead_projectDisabled(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addProject("github", "org", "repo")
+
+	state := testApplyResource(t, resourceProject(), nil, testProjectConfig(nil), api.client())
+
+	api.disableProject("github", "org", "repo")
+
+	// a new operation starts with a new client, and so a fresh list of projects
+	client := api.client()
+	if state := testRefreshResource(t, resourceProject(), state, client); state != nil {
+		t.Errorf("Expected the disabled project to be removed from state, got: %v.", state.Attributes)
+	}
+
+	state = testApplyResource(t, resourceProject(), nil, testProjectConfig(nil), client)
+	if state == nil || !api.projects["github/org/repo"].followed {
+		t.Error("Expected the project to be followed again.")
+	}
+}
+
+func TestResourceProjectRead_nameCase(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addProject("github", "org", "repo").organizationName = "Org"
+	client := api.client()
+
+	state := testApplyResource(t, resourceProject(), nil, testProjectConfig(nil), client)
+	if state.Attributes["account"] != "org" {
+		t.Errorf("account was incorrect, got: %s, want: org.", state.Attributes["account"])
+	}
+
+	state = testRefreshResource(t, resourceProject(), state, api.client())
+	if diff := testPlanResource(t, resourceProject(), state, testProjectConfig(nil), client); diff != nil && !diff.Empty() {
+		t.Errorf("Expected a difference in case to plan no changes, got: %v.", diff)
+	}
+}
+
+func TestVariableValuesSensitive(t *testing.T) {
+	project := resourceProject().Schema
+	values := map[string]*schema.Schema{
+		"circleci_project.variables":          project["variables"],
+		"circleci_project.variable.value":     project["variable"].Elem.(*schema.Resource).Schema["value"],
+		"circleci_environment_variable.value": resourceEnvironmentVariable().Schema["value"],
+	}
+
+	for name, s := range values {
+		if !s.Sensitive {
+			t.Errorf("%s is not sensitive.", name)
+		}
+	}
+}
+
+func TestResourceProjectPlanValidation(t *testing.T) {
+	api := newFakeAPI(t)
+	client := api.client()
+
+	cases := []struct {
+		name   string
+		config map[string]interface{}
+	}{
+		{
+			name:   "invalid variable name",
+			config: testProjectConfig(map[string]string{"X-FOO": "bar"}),
+		},
+		{
+			name:   "empty value",
+			config: testProjectConfig(map[string]string{"X_FOO": ""}),
+		},
+		{
+			name: "invalid variables key",
+			config: map[string]interface{}{
+				"account":   "org",
+				"project":   "repo",
+				"variables": map[string]interface{}{"1FOO": "bar"},
+			},
+		},
+		{
+			name: "invalid dotenv variable",
+			config: map[string]interface{}{
+				"account":          "org",
+				"project":          "repo",
+				"variables_dotenv": "X_FOO=\n",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diags := resourceProject().Validate(terraform.NewResourceConfigRaw(tc.config)); !diags.HasError() {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	diags := resourceProject().Validate(terraform.NewResourceConfigRaw(map[string]interface{}{
+		"account":   "org",
+		"project":   "repo",
+		"variables": map[string]interface{}{"CIRCLE_BRANCH": "main"},
+	}))
+	if diags.HasError() || len(diags) != 1 {
+		t.Errorf("Expected a warning for the reserved prefix, got: %+v.", diags)
+	}
+
+	duplicate := testProjectConfig(nil)
+	duplicate["variable"] = []interface{}{
+		map[string]interface{}{"name": "X_FOO", "value": "bar"},
+		map[string]interface{}{"name": "X_FOO", "value": "baz"},
+	}
+
+	invalidAccount := testProjectConfig(nil)
+	invalidAccount["account"] = "my_org"
+
+	for name, config := range map[string]map[string]interface{}{"duplicate": duplicate, "invalid account": invalidAccount} {
+		if _, err := resourceProject().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), client); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestDiffEnvironmentVariables(t *testing.T) {
+	set := func(vars map[string]string) *schema.Set {
+		s := schema.NewSet(variableHash, nil)
+		for name, value := range vars {
+			s.Add(map[string]interface{}{"name": name, "value": value})
+		}
+		return s
+	}
+
+	state := schema.NewSet(variableHash, nil)
+	for name, value := range map[string]string{"KEEP": "keep", "CHANGE": "old", "REMOVE": "remove"} {
+		state.Add(map[string]interface{}{
+			"name":         name,
+			"value":        maskCircleCiSecret(value),
+			"value_sha256": hashCircleCiSecret(name, value),
+		})
+	}
+
+	upserts, deletes := diffEnvironmentVariables(
+		state,
+		set(map[string]string{"KEEP": "keep", "CHANGE": "new", "ADD": "add"}),
+	)
+
+	expectedUpserts := []EnvVar{{Name: "ADD", Value: "add"}, {Name: "CHANGE", Value: "new"}}
+	if !reflect.DeepEqual(upserts, expectedUpserts) {
+		t.Errorf("Upserts were incorrect, got: %v, want: %v.", upserts, expectedUpserts)
+	}
+
+	expectedDeletes := []string{"REMOVE"}
+	if !reflect.DeepEqual(deletes, expectedDeletes) {
+		t.Errorf("Deletes were incorrect, got: %v, want: %v.", deletes, expectedDeletes)
+	}
+}
+
+// variableHashKey returns the key of a variable block in flatmap state
+func variableHashKey(name, value string) string {
+	return fmt.Sprint(variableHash(map[string]interface{}{
+		"name":         name,
+		"value":        maskCircleCiSecret(value),
+		"value_sha256": hashCircleCiSecret(name, value),
+	}))
+}
+
+// testProjectConfig returns the configuration of github/org/repo with the given variables
+func testProjectConfig(vars map[string]string) map[string]interface{} {
+	variables := []interface{}{}
+	for name, value := range vars {
+		variables = append(variables, map[string]interface{}{"name": name, "value": value})
+	}
+
+	return map[string]interface{}{
+		"vcs_type": "github",
+		"account":  "org",
+		"project":  "repo",
+		"variable": variables,
+	}
+}
+
 func testCheckCircleCIProjectExists(n string, proj *Project) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		rs, ok := s.RootModule().Resources[n]
@@ -65,7 +668,7 @@ func testCheckCircleCIProjectExists(n string, proj *Project) resource.TestCheckF
 
 		conn := testAccProvider.Meta().(*ApiClient)
 
-		gotProj, err := conn.GetProject("github", rs.Primary.Attributes["account"], rs.Primary.Attributes["project"])
+		gotProj, err := conn.GetProject(context.Background(), "github", rs.Primary.Attributes["account"], rs.Primary.Attributes["project"])
 
 		if err != nil {
 			return err
@@ -100,7 +703,7 @@ func testCheckCircleCIProjectDestroy(s *terraform.State) error {
 			continue
 		}
 
-		_, err := conn.GetProject("github", rs.Primary.Attributes["account"], rs.Primary.Attributes["project"])
+		_, err := conn.GetProject(context.Background(), "github", rs.Primary.Attributes["account"], rs.Primary.Attributes["project"])
 
 		if err == nil {
 			return fmt.Errorf("Expected CircleCi project to be gone, but was still found.")
@@ -119,9 +722,8 @@ resource "circleci_project" "project" {
   account  = "%s"
   project  = "%s"
 
-  variable {
-    name  = "__________X_FOO"
-    value = "bar"
+  variables = {
+    __________X_FOO = "bar"
   }
 }
 `, org, repo)
@@ -134,14 +736,9 @@ resource "circleci_project" "project" {
   account  = "%s"
   project  = "%s"
 
-  variable {
-    name  = "RENAMED_X_FOO"
-    value = "bar"
-  }
-
-  variable {
-    name  = "X_FIZZ"
-    value = "buzz"
+  variables = {
+    RENAMED_X_FOO = "bar"
+    X_FIZZ        = "buzz"
   }
 }
 `, org, repo)
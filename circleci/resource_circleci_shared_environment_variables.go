@@ -0,0 +1,351 @@
+package circleci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func resourceSharedEnvironmentVariables() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceSharedEnvironmentVariablesCreate,
+		ReadContext:   resourceSharedEnvironmentVariablesRead,
+		UpdateContext: resourceSharedEnvironmentVariablesUpdate,
+		DeleteContext: resourceSharedEnvironmentVariablesDelete,
+
+		Schema: map[string]*schema.Schema{
+			"project_slugs": {
+				Type:     schema.TypeSet,
+				Required: true,
+				MinItems: 1,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validateProjectSlug,
+				},
+				Description: "Slugs of the projects the variables are written to, e.g. gh/org/repo.",
+			},
+			"variables": {
+				Type:             schema.TypeMap,
+				Required:         true,
+				Sensitive:        true,
+				Elem:             &schema.Schema{Type: schema.TypeString},
+				Description:      "Environment variables written to every project, keyed by name.",
+				ValidateFunc:     validateEnvVarMap,
+				DiffSuppressFunc: suppressUnchangedVariable,
+			},
+			"variables_sha256": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Salted SHA-256 of the values in variables last written by Terraform, keyed by name.",
+			},
+			"parallelism": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      10,
+				ValidateFunc: validateIntAtLeast(1),
+				Description:  "Number of projects reconciled at the same time.",
+			},
+		},
+	}
+}
+
+func resourceSharedEnvironmentVariablesCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	d.SetId(resource.UniqueId())
+
+	return resourceSharedEnvironmentVariablesUpdate(ctx, d, meta)
+}
+
+// resourceSharedEnvironmentVariablesRead checks every project still holds the
+// variables last written. A variable missing from a project, or whose masked
+// value differs from the one written, has its value and hash cleared from
+// state so the next plan writes it to every project again. A project that
+// can't be read is reported as a warning and left out, so it neither fails the
+// refresh of the others nor has every variable written again.
+func resourceSharedEnvironmentVariablesRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*ApiClient)
+
+	slugs := expandStringSet(d.Get("project_slugs").(*schema.Set))
+
+	var mu sync.Mutex
+	envVars := make(map[string]map[string]string, len(slugs))
+
+	errs := forEachProject(ctx, slugs, d.Get("parallelism").(int), func(ctx context.Context, slug string) error {
+		vcstype, account, reponame, err := expandProjectSlug(slug)
+		if err != nil {
+			return err
+		}
+
+		vars, err := client.ListEnvVars(ctx, vcstype, account, reponame)
+		if errors.Is(err, ErrNotFound) {
+			tflog.Warn(ctx, "CircleCI project not found, its shared environment variables will be written again", map[string]interface{}{"project_slug": slug})
+			vars, err = nil, nil
+		}
+		if err != nil {
+			return err
+		}
+
+		masked := make(map[string]string, len(vars))
+		for _, v := range vars {
+			masked[v.Name] = v.Value
+		}
+
+		mu.Lock()
+		envVars[slug] = masked
+		mu.Unlock()
+
+		return nil
+	})
+
+	diags := projectWarningDiagnostics("Unable to read shared environment variables", errs)
+
+	hashes, _ := d.GetChange("variables_sha256")
+	declared := variablesSet(d.Get("variables").(map[string]interface{}), hashes.(map[string]interface{}))
+
+	values := make(map[string]interface{})
+	newHashes := make(map[string]interface{})
+
+	for _, raw := range declared.List() {
+		data := raw.(map[string]interface{})
+		name := data["name"].(string)
+		masked, sum := variableValueHash(data)
+
+		inSync := true
+		for _, slug := range slugs {
+			if _, failed := errs[slug]; failed {
+				continue
+			}
+			if value, ok := envVars[slug][name]; !ok || value != masked {
+				tflog.Debug(ctx, "Shared environment variable out of sync", map[string]interface{}{"project_slug": slug, "name": name})
+				inSync = false
+			}
+		}
+
+		if !inSync {
+			values[name] = ""
+			continue
+		}
+
+		values[name] = masked
+		if sum != "" {
+			newHashes[name] = sum
+		}
+	}
+
+	if err := d.Set("variables", values); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	if err := d.Set("variables_sha256", newHashes); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	return diags
+}
+
+// resourceSharedEnvironmentVariablesUpdate reconciles every project, writing
+// changed variables and deleting removed ones. A project that fails doesn't
+// stop the others from being reconciled; its failure is reported as a warning
+// once all are done, so a new resource isn't tainted and destroyed, and the
+// state keeps what it didn't get done for the next plan to retry:
+// variables it wasn't written have their value and hash cleared, variables it
+// still holds stay in state, and so does the project if it was removed.
+func resourceSharedEnvironmentVariablesUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*ApiClient)
+
+	oSlugs, nSlugs := d.GetChange("project_slugs")
+	oMap, nMap := d.GetChange("variables")
+	hashes, _ := d.GetChange("variables_sha256")
+
+	o := variablesSet(oMap.(map[string]interface{}), hashes.(map[string]interface{}))
+	n := variablesSet(nMap.(map[string]interface{}), hashes.(map[string]interface{}))
+
+	upserts, deletes := diffEnvironmentVariables(o, n)
+
+	// projects new to the resource get every variable
+	added := make(map[string]bool)
+	for _, slug := range expandStringSet(nSlugs.(*schema.Set).Difference(oSlugs.(*schema.Set))) {
+		added[slug] = true
+	}
+	all, _ := diffEnvironmentVariables(schema.NewSet(variableHash, nil), n)
+
+	var mu sync.Mutex
+	pending := make(map[string]bool)
+
+	errs := forEachProject(ctx, expandStringSet(nSlugs.(*schema.Set)), d.Get("parallelism").(int), func(ctx context.Context, slug string) error {
+		vars := upserts
+		if added[slug] {
+			vars = all
+		}
+
+		names, err := reconcileProjectVariables(ctx, client, slug, vars, deletes)
+
+		mu.Lock()
+		for _, name := range names {
+			pending[name] = true
+		}
+		mu.Unlock()
+
+		return err
+	})
+
+	// projects removed from the resource lose every variable it wrote
+	removed := expandStringSet(oSlugs.(*schema.Set).Difference(nSlugs.(*schema.Set)))
+	_, names := diffEnvironmentVariables(o, schema.NewSet(variableHash, nil))
+
+	removeErrs := forEachProject(ctx, removed, d.Get("parallelism").(int), func(ctx context.Context, slug string) error {
+		_, err := reconcileProjectVariables(ctx, client, slug, nil, names)
+		return err
+	})
+	for slug, err := range removeErrs {
+		errs[slug] = err
+	}
+
+	diags := resourceSharedEnvironmentVariablesRead(ctx, d, meta)
+
+	// Read can't tell a variable a project wasn't written from one it was when
+	// their masked values are the same, so what is pending is set aside here
+	values := d.Get("variables").(map[string]interface{})
+	sums := d.Get("variables_sha256").(map[string]interface{})
+	for name := range pending {
+		values[name] = ""
+		delete(sums, name)
+	}
+
+	slugs := d.Get("project_slugs").(*schema.Set).List()
+	for slug := range removeErrs {
+		slugs = append(slugs, slug)
+	}
+
+	d.Set("variables", values)
+	d.Set("variables_sha256", sums)
+	d.Set("project_slugs", slugs)
+
+	return append(diags, projectWarningDiagnostics("Unable to write shared environment variables", errs)...)
+}
+
+func resourceSharedEnvironmentVariablesDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client := meta.(*ApiClient)
+
+	names := make([]string, 0)
+	for name := range d.Get("variables").(map[string]interface{}) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	errs := forEachProject(ctx, expandStringSet(d.Get("project_slugs").(*schema.Set)), d.Get("parallelism").(int), func(ctx context.Context, slug string) error {
+		_, err := reconcileProjectVariables(ctx, client, slug, nil, names)
+		return err
+	})
+
+	return projectErrorDiagnostics("Error deleting shared environment variables", errs)
+}
+
+// reconcileProjectVariables writes and deletes variables of one project.
+// Variables already gone, or projects that no longer exist, are fine to delete
+// from. It stops at the first failure, and returns the names of the variables
+// it didn't write or delete along with the error.
+func reconcileProjectVariables(ctx context.Context, client *ApiClient, slug string, upserts []EnvVar, deletes []string) ([]string, error) {
+	pending := make([]string, 0, len(upserts)+len(deletes))
+	for _, v := range upserts {
+		pending = append(pending, v.Name)
+	}
+	pending = append(pending, deletes...)
+
+	vcstype, account, reponame, err := expandProjectSlug(slug)
+	if err != nil {
+		return pending, err
+	}
+
+	for _, v := range upserts {
+		if _, err := client.AddEnvVar(ctx, vcstype, account, reponame, v.Name, v.Value); err != nil {
+			return pending, fmt.Errorf("unable to add environment variable %q: %w", v.Name, err)
+		}
+		pending = pending[1:]
+	}
+
+	for _, name := range deletes {
+		err := client.DeleteEnvVar(ctx, vcstype, account, reponame, name)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return pending, fmt.Errorf("unable to delete environment variable %q: %w", name, err)
+		}
+		pending = pending[1:]
+	}
+
+	return nil, nil
+}
+
+// forEachProject calls fn for every project slug, at most parallelism at a
+// time, and returns the errors by slug
+func forEachProject(ctx context.Context, slugs []string, parallelism int, fn func(context.Context, string) error) map[string]error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(map[string]error)
+
+	sem := make(chan struct{}, parallelism)
+
+	for _, slug := range slugs {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(slug string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := fn(ctx, slug); err != nil {
+				mu.Lock()
+				errs[slug] = err
+				mu.Unlock()
+			}
+		}(slug)
+	}
+
+	wg.Wait()
+
+	return errs
+}
+
+// projectErrorDiagnostics returns an error diagnostic per failed project
+func projectErrorDiagnostics(summary string, errs map[string]error) diag.Diagnostics {
+	slugs := make([]string, 0, len(errs))
+	for slug := range errs {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+
+	var diags diag.Diagnostics
+	for _, slug := range slugs {
+		diags = append(diags, apiErrorDiagnostics(summary, fmt.Sprintf("CircleCI project %s", slug), errs[slug], cty.GetAttrPath("project_slugs"))...)
+	}
+
+	return diags
+}
+
+// projectWarningDiagnostics returns a warning diagnostic per failed project
+func projectWarningDiagnostics(summary string, errs map[string]error) diag.Diagnostics {
+	diags := projectErrorDiagnostics(summary, errs)
+	for i := range diags {
+		diags[i].Severity = diag.Warning
+	}
+
+	return diags
+}
+
+// expandStringSet returns the sorted strings of a set
+func expandStringSet(s *schema.Set) []string {
+	values := make([]string, 0, s.Len())
+	for _, v := range s.List() {
+		values = append(values, v.(string))
+	}
+	sort.Strings(values)
+
+	return values
+}
@@ -0,0 +1,240 @@
+package circleci
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestResourceSharedEnvironmentVariables(t *testing.T) {
+	api := newFakeAPI(t)
+	for _, repo := range []string{"one", "two", "three"} {
+		api.addProject("github", "org", repo)
+	}
+	api.setEnvVar("github", "org", "one", "UNMANAGED", "other")
+	client := api.client()
+
+	config := func(slugs []interface{}, vars map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"project_slugs": slugs,
+			"variables":     vars,
+			"parallelism":   2,
+		}
+	}
+
+	state := testApplyResource(t, resourceSharedEnvironmentVariables(), nil, config(
+		[]interface{}{"gh/org/one", "gh/org/two"},
+		map[string]interface{}{"REGISTRY_USER": "deploy", "REGISTRY_PASSWORD": "aaaa1234"},
+	), client)
+
+	expected := map[string]string{"REGISTRY_USER": "deploy", "REGISTRY_PASSWORD": "aaaa1234"}
+	if got := api.envVars("github", "org", "two"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Environment variables were incorrect, got: %v, want: %v.", got, expected)
+	}
+
+	// rotate a password, drop a variable, move from project two to three
+	state = testApplyResource(t, resourceSharedEnvironmentVariables(), state, config(
+		[]interface{}{"gh/org/one", "gh/org/three"},
+		map[string]interface{}{"REGISTRY_PASSWORD": "bbbb1234"},
+	), client)
+
+	for repo, expected := range map[string]map[string]string{
+		"one":   {"UNMANAGED": "other", "REGISTRY_PASSWORD": "bbbb1234"},
+		"two":   {},
+		"three": {"REGISTRY_PASSWORD": "bbbb1234"},
+	} {
+		if got := api.envVars("github", "org", repo); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Environment variables of %s were incorrect, got: %v, want: %v.", repo, got, expected)
+		}
+	}
+
+	diff, err := resourceSharedEnvironmentVariables().Diff(context.Background(), state, terraform.NewResourceConfigRaw(config(
+		[]interface{}{"gh/org/one", "gh/org/three"},
+		map[string]interface{}{"REGISTRY_PASSWORD": "bbbb1234"},
+	)), client)
+	if err != nil {
+		t.Fatalf("unexpected error planning: %s", err)
+	}
+	if diff != nil && !diff.Empty() {
+		t.Errorf("Expected no changes, got: %v.", diff)
+	}
+
+	// drift in one project is written again
+	api.setEnvVar("github", "org", "three", "REGISTRY_PASSWORD", "cccc5678")
+	state = testRefreshResource(t, resourceSharedEnvironmentVariables(), state, client)
+	state = testApplyResource(t, resourceSharedEnvironmentVariables(), state, config(
+		[]interface{}{"gh/org/one", "gh/org/three"},
+		map[string]interface{}{"REGISTRY_PASSWORD": "bbbb1234"},
+	), client)
+
+	if got := api.envVars("github", "org", "three")["REGISTRY_PASSWORD"]; got != "bbbb1234" {
+		t.Errorf("Value was incorrect, got: %s, want: bbbb1234.", got)
+	}
+
+	if diags := resourceSharedEnvironmentVariablesDelete(context.Background(), resourceSharedEnvironmentVariables().Data(state), client); diags.HasError() {
+		t.Fatalf("unexpected error deleting: %+v", diags)
+	}
+
+	if got := api.envVars("github", "org", "one"); !reflect.DeepEqual(got, map[string]string{"UNMANAGED": "other"}) {
+		t.Errorf("Environment variables were incorrect, got: %v.", got)
+	}
+}
+
+func TestResourceSharedEnvironmentVariables_projectFailure(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addProject("github", "org", "one")
+	api.addProject("github", "org", "three")
+	client := api.client()
+
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"project_slugs": []interface{}{"gh/org/one", "gh/org/missing", "gh/org/three"},
+		"variables":     map[string]interface{}{"X_FOO": "bar"},
+	})
+
+	r := resourceSharedEnvironmentVariables()
+	diff, err := r.Diff(context.Background(), nil, config, client)
+	if err != nil {
+		t.Fatalf("unexpected error planning: %s", err)
+	}
+
+	// a failed project doesn't fail the creation, which would taint the resource
+	state, diags := r.Apply(context.Background(), nil, diff, client)
+	if diags.HasError() || len(diags) != 1 || diags[0].Severity != diag.Warning {
+		t.Fatalf("Expected one warning for the missing project, got: %+v", diags)
+	}
+	if state == nil || state.ID == "" {
+		t.Fatalf("Expected the resource to be created, got: %v.", state)
+	}
+
+	for _, repo := range []string{"one", "three"} {
+		if got := api.envVars("github", "org", repo); !reflect.DeepEqual(got, map[string]string{"X_FOO": "bar"}) {
+			t.Errorf("Environment variables of %s were incorrect, got: %v.", repo, got)
+		}
+	}
+
+	// the failed project is retried by the next plan
+	diff, err = r.Diff(context.Background(), state, config, client)
+	if err != nil {
+		t.Fatalf("unexpected error planning: %s", err)
+	}
+	if diff == nil || diff.Attributes["variables.X_FOO"] == nil {
+		t.Errorf("Expected X_FOO to be written again, got: %v.", diff)
+	}
+}
+
+func TestResourceSharedEnvironmentVariables_partialWrite(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addProject("github", "org", "one")
+	api.addProject("github", "org", "two")
+	client := api.client()
+
+	config := func(slugs ...interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"project_slugs": slugs,
+			"variables":     map[string]interface{}{"X_FOO": "bbbb1234"},
+		}
+	}
+
+	r := resourceSharedEnvironmentVariables()
+	state := testApplyResource(t, r, nil, map[string]interface{}{
+		"project_slugs": []interface{}{"gh/org/one", "gh/org/two"},
+		"variables":     map[string]interface{}{"X_FOO": "aaaa1234"},
+	}, client)
+
+	api.forbidEnvVars("github", "org", "two", "POST", "DELETE")
+
+	// a rotation that fails in one project keeps the same masked value there
+	state, diags := r.Apply(context.Background(), state, testPlanResource(t, r, state, config("gh/org/one", "gh/org/two"), client), client)
+	if diags.HasError() || len(diags) != 1 || diags[0].Severity != diag.Warning {
+		t.Fatalf("Expected one warning for project two, got: %+v", diags)
+	}
+
+	if got := api.envVars("github", "org", "two")["X_FOO"]; got != "aaaa1234" {
+		t.Fatalf("Value was incorrect, got: %s, want: aaaa1234.", got)
+	}
+
+	diff := testPlanResource(t, r, state, config("gh/org/one", "gh/org/two"), client)
+	if diff == nil || diff.Attributes["variables.X_FOO"] == nil {
+		t.Errorf("Expected X_FOO to be written again, got: %v.", diff)
+	}
+
+	// a variable that can't be deleted stays in state
+	removal := map[string]interface{}{
+		"project_slugs": []interface{}{"gh/org/one", "gh/org/two"},
+		"variables":     map[string]interface{}{"X_BAR": "baz"},
+	}
+	next, diags := r.Apply(context.Background(), state, testPlanResource(t, r, state, removal, client), client)
+	if diags.HasError() || len(diags) != 1 {
+		t.Fatalf("Expected one warning for project two, got: %+v", diags)
+	}
+
+	if _, ok := next.Attributes["variables.X_FOO"]; !ok {
+		t.Errorf("Expected X_FOO to be kept in state, got: %v.", next.Attributes)
+	}
+
+	diff = testPlanResource(t, r, next, removal, client)
+	if diff == nil || diff.Attributes["variables.X_FOO"] == nil || !diff.Attributes["variables.X_FOO"].NewRemoved {
+		t.Errorf("Expected X_FOO to be deleted again, got: %v.", diff)
+	}
+
+	// a project whose variables can't be deleted stays in state
+	state, diags = r.Apply(context.Background(), state, testPlanResource(t, r, state, config("gh/org/one"), client), client)
+	if diags.HasError() || len(diags) != 1 {
+		t.Fatalf("Expected one warning for project two, got: %+v", diags)
+	}
+
+	if state.Attributes["project_slugs.#"] != "2" {
+		t.Errorf("Expected project two to be kept in state, got: %v.", state.Attributes)
+	}
+
+	api.forbidEnvVars("github", "org", "two")
+	state = testApplyResource(t, r, state, config("gh/org/one"), client)
+
+	if got := api.envVars("github", "org", "two"); len(got) != 0 {
+		t.Errorf("Environment variables of two were incorrect, got: %v.", got)
+	}
+
+	if diff := testPlanResource(t, r, state, config("gh/org/one"), client); diff != nil && !diff.Empty() {
+		t.Errorf("Expected no changes, got: %v.", diff)
+	}
+}
+
+func TestResourceSharedEnvironmentVariables_readFailure(t *testing.T) {
+	api := newFakeAPI(t)
+	api.addProject("github", "org", "one")
+	api.addProject("github", "org", "two")
+	client := api.client()
+
+	r := resourceSharedEnvironmentVariables()
+	state := testApplyResource(t, r, nil, map[string]interface{}{
+		"project_slugs": []interface{}{"gh/org/one", "gh/org/two"},
+		"variables":     map[string]interface{}{"X_FOO": "aaaa1234"},
+	}, client)
+
+	api.forbidEnvVars("github", "org", "two", "GET")
+
+	state, diags := r.RefreshWithoutUpgrade(context.Background(), state, client)
+	if diags.HasError() || len(diags) != 1 || diags[0].Severity != diag.Warning {
+		t.Fatalf("Expected one warning for project two, got: %+v", diags)
+	}
+
+	// the project that couldn't be read doesn't plan a rewrite everywhere
+	if state == nil || state.Attributes["variables.X_FOO"] != "xxxx1234" || state.Attributes["variables_sha256.X_FOO"] != hashCircleCiSecret("X_FOO", "aaaa1234") {
+		t.Errorf("Expected X_FOO to be left as it was, got: %v.", state)
+	}
+
+	// the projects that could be read are still checked
+	api.setEnvVar("github", "org", "one", "X_FOO", "cccc5678")
+
+	state, diags = r.RefreshWithoutUpgrade(context.Background(), state, client)
+	if diags.HasError() || len(diags) != 1 {
+		t.Fatalf("Expected one warning for project two, got: %+v", diags)
+	}
+
+	if state == nil || state.Attributes["variables.X_FOO"] != "" {
+		t.Errorf("Expected X_FOO to be written again, got: %v.", state)
+	}
+}
@@ -0,0 +1,164 @@
+package circleci
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMinRetryWait = 1 * time.Second
+	defaultMaxRetryWait = 30 * time.Second
+)
+
+type retrySafeKey struct{}
+
+// withRetrySafe marks requests made with the returned context as safe to retry
+// even though their method is not idempotent, e.g. for POST endpoints that
+// behave as upserts
+func withRetrySafe(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retrySafeKey{}, true)
+}
+
+func isRetrySafe(ctx context.Context) bool {
+	safe, _ := ctx.Value(retrySafeKey{}).(bool)
+	return safe
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+func (c *ApiClient) minRetryWait() time.Duration {
+	if c.MinRetryWait <= 0 {
+		return defaultMinRetryWait
+	}
+
+	return c.MinRetryWait
+}
+
+func (c *ApiClient) maxRetryWait() time.Duration {
+	if c.MaxRetryWait <= 0 {
+		return defaultMaxRetryWait
+	}
+
+	return c.MaxRetryWait
+}
+
+// retryWait decides whether a request should be retried after the given attempt
+// (counting from 0) and how long to wait before doing so.
+//
+// Rate limited requests were rejected before CircleCI acted on them, so they are
+// always retried. Transport errors and server errors are only retried when the
+// request is safe to repeat.
+func (c *ApiClient) retryWait(attempt int, retrySafe bool, resp *http.Response, err error) (time.Duration, bool) {
+	if attempt >= c.MaxRetries {
+		return 0, false
+	}
+
+	if err != nil {
+		// the context was cancelled or timed out, retrying won't help
+		if isContextError(err) {
+			return 0, false
+		}
+
+		return c.backoff(attempt), retrySafe
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if !retrySafe {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+
+	wait, ok := serverRetryWait(resp.Header, time.Now())
+	if !ok {
+		return c.backoff(attempt), true
+	}
+
+	// the server asked us to wait longer than we are allowed to, give up instead
+	if wait > c.maxRetryWait() {
+		return 0, false
+	}
+
+	return wait, true
+}
+
+// backoff returns the exponential backoff for the given attempt, with jitter
+// spreading the wait over the upper half of the interval
+func (c *ApiClient) backoff(attempt int) time.Duration {
+	wait := c.minRetryWait() << uint(attempt)
+	if wait <= 0 || wait > c.maxRetryWait() {
+		wait = c.maxRetryWait()
+	}
+
+	half := int64(wait / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// serverRetryWait reads how long the server wants us to wait from the
+// Retry-After header, falling back to the X-RateLimit-* headers
+func serverRetryWait(h http.Header, now time.Time) (time.Duration, bool) {
+	if v := h.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+
+		if at, err := http.ParseTime(v); err == nil {
+			return nonNegative(at.Sub(now)), true
+		}
+	}
+
+	if h.Get("X-RateLimit-Remaining") != "0" {
+		return 0, false
+	}
+
+	reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil || reset < 0 {
+		return 0, false
+	}
+
+	// the reset is either a unix timestamp or a number of seconds from now
+	if reset > 1e9 {
+		return nonNegative(time.Unix(reset, 0).Sub(now)), true
+	}
+
+	return time.Duration(reset) * time.Second, true
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+
+	return d
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
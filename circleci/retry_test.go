@@ -0,0 +1,165 @@
+package circleci
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestApiClientRetries(t *testing.T) {
+	cases := []struct {
+		name          string
+		method        string
+		ctx           context.Context
+		statuses      []int
+		expectedCalls int
+		expectError   bool
+	}{
+		{
+			name:          "idempotent server error",
+			method:        "GET",
+			ctx:           context.Background(),
+			statuses:      []int{503, 502, 200},
+			expectedCalls: 3,
+		},
+		{
+			name:          "non idempotent server error",
+			method:        "POST",
+			ctx:           context.Background(),
+			statuses:      []int{503, 200},
+			expectedCalls: 1,
+			expectError:   true,
+		},
+		{
+			name:          "retry safe server error",
+			method:        "POST",
+			ctx:           withRetrySafe(context.Background()),
+			statuses:      []int{503, 200},
+			expectedCalls: 2,
+		},
+		{
+			name:          "non idempotent rate limited",
+			method:        "POST",
+			ctx:           context.Background(),
+			statuses:      []int{429, 200},
+			expectedCalls: 2,
+		},
+		{
+			name:          "client error",
+			method:        "GET",
+			ctx:           context.Background(),
+			statuses:      []int{404, 200},
+			expectedCalls: 1,
+			expectError:   true,
+		},
+		{
+			name:          "retries exhausted",
+			method:        "GET",
+			ctx:           context.Background(),
+			statuses:      []int{500, 500, 500, 500},
+			expectedCalls: 4,
+			expectError:   true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tc.statuses[calls]
+				calls++
+
+				if status == http.StatusTooManyRequests {
+					w.Header().Set("Retry-After", "0")
+				}
+				w.WriteHeader(status)
+				w.Write([]byte(`{}`))
+			}))
+			defer server.Close()
+
+			baseURL, _ := url.Parse(server.URL + "/api/v1.1/")
+			client := &ApiClient{
+				BaseURL:      baseURL,
+				MaxRetries:   3,
+				MinRetryWait: time.Millisecond,
+				MaxRetryWait: 10 * time.Millisecond,
+			}
+
+			err := client.request(tc.ctx, tc.method, "projects", nil, nil, nil)
+
+			if tc.expectError && err == nil {
+				t.Error("expected an error")
+			}
+
+			if !tc.expectError && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+
+			if calls != tc.expectedCalls {
+				t.Errorf("Number of calls was incorrect, got: %d, want: %d.", calls, tc.expectedCalls)
+			}
+		})
+	}
+}
+
+func TestServerRetryWait(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+
+	cases := []struct {
+		name     string
+		headers  map[string]string
+		expected time.Duration
+		ok       bool
+	}{
+		{
+			name: "no headers",
+		},
+		{
+			name:     "retry after seconds",
+			headers:  map[string]string{"Retry-After": "5"},
+			expected: 5 * time.Second,
+			ok:       true,
+		},
+		{
+			name:     "retry after date",
+			headers:  map[string]string{"Retry-After": now.Add(10 * time.Second).UTC().Format(http.TimeFormat)},
+			expected: 10 * time.Second,
+			ok:       true,
+		},
+		{
+			name:     "rate limit reset seconds",
+			headers:  map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "7"},
+			expected: 7 * time.Second,
+			ok:       true,
+		},
+		{
+			name:     "rate limit reset timestamp",
+			headers:  map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "1600000003"},
+			expected: 3 * time.Second,
+			ok:       true,
+		},
+		{
+			name:    "rate limit not exhausted",
+			headers: map[string]string{"X-RateLimit-Remaining": "10", "X-RateLimit-Reset": "7"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tc.headers {
+				h.Set(k, v)
+			}
+
+			wait, ok := serverRetryWait(h, now)
+
+			if ok != tc.ok || wait != tc.expected {
+				t.Errorf("Retry wait was incorrect, got: %s (%t), want: %s (%t).", wait, ok, tc.expected, tc.ok)
+			}
+		})
+	}
+}
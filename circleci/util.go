@@ -1,7 +1,20 @@
 package circleci
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
 func maskCircleCiSecret(value string) string {
@@ -9,6 +22,8 @@ func maskCircleCiSecret(value string) string {
 	var take int
 
 	switch len := len(value); len {
+	case 0:
+		take = 0
 	case 1:
 		take = len - 0
 	case 2, 3:
@@ -23,3 +38,216 @@ func maskCircleCiSecret(value string) string {
 
 	return fmt.Sprintf("xxxx%s", value[take:])
 }
+
+// secretHashSalt prefixes every hashed secret, so hashes in state can't be
+// looked up in tables of plain SHA-256 digests
+const secretHashSalt = "terraform-provider-circleci"
+
+// unknownValue is the placeholder the SDK uses for computed values that are not
+// known yet while planning
+const unknownValue = "74D93920-ED26-11E3-AC10-0800200C9A66"
+
+// hashCircleCiSecret returns a salted SHA-256 of a secret value, salted further
+// with the name of the variable holding it
+func hashCircleCiSecret(name, value string) string {
+	sum := sha256.Sum256([]byte(secretHashSalt + ":" + name + ":" + value))
+	return hex.EncodeToString(sum[:])
+}
+
+// getRawConfigString returns the configured value of a string argument, or ""
+// when it isn't set. Write-only values never reach the plan or state, and
+// secrets planned as hashes by customizeDiffSecretHashes only reach them
+// hashed, so they are only found in the raw configuration.
+func getRawConfigString(d *schema.ResourceData, k string) (string, diag.Diagnostics) {
+	v, diags := d.GetRawConfigAt(cty.GetAttrPath(k))
+	if diags.HasError() {
+		return "", diags
+	}
+
+	if v.IsNull() || !v.IsKnown() || !v.Type().Equals(cty.String) {
+		return "", nil
+	}
+
+	return v.AsString(), nil
+}
+
+// customizeDiffSecretHashes plans hashCircleCiSecret of the configured value of
+// each secret argument in keys, salted with the name argument, instead of the
+// value itself. A StateFunc can't be used for this as it doesn't see the name.
+// The arguments must be Computed, for their planned values to be set.
+func customizeDiffSecretHashes(keys ...string) schema.CustomizeDiffFunc {
+	return func(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+		for _, k := range keys {
+			v, diags := d.GetRawConfigAt(cty.GetAttrPath(k))
+			if diags.HasError() {
+				return fmt.Errorf("%s: %s", diags[0].Summary, diags[0].Detail)
+			}
+
+			var err error
+			switch {
+			case v.IsNull():
+				err = d.SetNew(k, "")
+			case !v.IsKnown() || !d.NewValueKnown("name"):
+				err = d.SetNewComputed(k)
+			default:
+				err = d.SetNew(k, hashCircleCiSecret(d.Get("name").(string), v.AsString()))
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// validateBase64Text checks that a value is base64 encoded text. CircleCI
+// stores environment variables as strings, so binary material has to stay
+// encoded and be decoded by the job.
+func validateBase64Text(v interface{}, k string) (ws []string, errs []error) {
+	b, err := base64.StdEncoding.DecodeString(v.(string))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("%s is not valid base64: %s", k, err))
+		return
+	}
+
+	if !utf8.Valid(b) {
+		errs = append(errs, fmt.Errorf("%s decodes to binary data, which CircleCI can't store; use value with the base64 encoded data and decode it in the job", k))
+	}
+	return
+}
+
+const (
+	// reservedEnvVarPrefix is the prefix of the variables CircleCI sets in every
+	// job, which project variables would shadow
+	reservedEnvVarPrefix = "CIRCLE_"
+
+	maxEnvVarNameLength  = 256
+	maxEnvVarValueLength = 128 * 1024
+)
+
+// checkEnvVarName returns an error for names that aren't POSIX environment
+// variable names, and a warning for names with the reserved prefix
+func checkEnvVarName(name string) (string, error) {
+	if !envVarNamePattern.MatchString(name) {
+		return "", fmt.Errorf("%q is not a valid environment variable name: it must start with a letter or underscore and contain only letters, digits and underscores", name)
+	}
+
+	if len(name) > maxEnvVarNameLength {
+		return "", fmt.Errorf("environment variable name %.16q... is longer than %d characters", name, maxEnvVarNameLength)
+	}
+
+	if strings.HasPrefix(strings.ToUpper(name), reservedEnvVarPrefix) {
+		return fmt.Sprintf("environment variable %s uses the %s prefix CircleCI reserves for its built-in variables", name, reservedEnvVarPrefix), nil
+	}
+
+	return "", nil
+}
+
+// checkEnvVarValue returns an error for values CircleCI rejects. It never
+// quotes the value.
+func checkEnvVarValue(value string) error {
+	if value == "" {
+		return fmt.Errorf("value is empty")
+	}
+
+	if len(value) > maxEnvVarValueLength {
+		return fmt.Errorf("value is longer than %d bytes", maxEnvVarValueLength)
+	}
+
+	return nil
+}
+
+func validateEnvVarName(v interface{}, k string) (ws []string, errs []error) {
+	warning, err := checkEnvVarName(v.(string))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("%s: %s", k, err))
+	}
+	if warning != "" {
+		ws = append(ws, fmt.Sprintf("%s: %s", k, warning))
+	}
+	return
+}
+
+func validateEnvVarValue(v interface{}, k string) (ws []string, errs []error) {
+	if err := checkEnvVarValue(v.(string)); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %s", k, err))
+	}
+	return
+}
+
+// validateEnvVarMap validates the names and values of a map of environment
+// variables
+func validateEnvVarMap(v interface{}, k string) (ws []string, errs []error) {
+	names := make([]string, 0)
+	for name := range v.(map[string]interface{}) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		w, e := validateEnvVarName(name, k)
+		ws, errs = append(ws, w...), append(errs, e...)
+
+		if value, ok := v.(map[string]interface{})[name].(string); ok && value != unknownValue {
+			_, e = validateEnvVarValue(value, fmt.Sprintf("%s[%q]", k, name))
+			errs = append(errs, e...)
+		}
+	}
+	return
+}
+
+// validateDotenv validates dotenv content and the variables it holds
+func validateDotenv(v interface{}, k string) (ws []string, errs []error) {
+	vars, err := parseDotenv(v.(string))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("%s: %s", k, err))
+		return
+	}
+
+	m := make(map[string]interface{}, len(vars))
+	for name, value := range vars {
+		m[name] = value
+	}
+
+	return validateEnvVarMap(m, k)
+}
+
+func validateProjectSlug(v interface{}, k string) (ws []string, errs []error) {
+	if _, _, _, err := parseProjectSlug(v.(string)); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %s", k, err))
+	}
+	return
+}
+
+func validateIntAtLeast(min int) schema.SchemaValidateFunc {
+	return func(v interface{}, k string) (ws []string, errs []error) {
+		if v.(int) < min {
+			errs = append(errs, fmt.Errorf("%s must be at least %d, got %d", k, min, v.(int)))
+		}
+		return
+	}
+}
+
+func validateFloatAtLeast(min float64) schema.SchemaValidateFunc {
+	return func(v interface{}, k string) (ws []string, errs []error) {
+		if v.(float64) < min {
+			errs = append(errs, fmt.Errorf("%s must be at least %v, got %v", k, min, v.(float64)))
+		}
+		return
+	}
+}
+
+func validateDuration(v interface{}, k string) (ws []string, errs []error) {
+	if _, err := time.ParseDuration(v.(string)); err != nil {
+		errs = append(errs, fmt.Errorf("%s must be a duration such as 30s or 2m: %s", k, err))
+	}
+	return
+}
+
+func validateRegexp(v interface{}, k string) (ws []string, errs []error) {
+	if _, err := regexp.Compile(v.(string)); err != nil {
+		errs = append(errs, fmt.Errorf("%s must be a regular expression: %s", k, err))
+	}
+	return
+}
@@ -1,6 +1,7 @@
 package circleci
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -71,3 +72,86 @@ func TestAccMaskCircleCiSecret(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckEnvVarName(t *testing.T) {
+	cases := []struct {
+		name    string
+		warning bool
+		invalid bool
+	}{
+		{name: "X_FOO"},
+		{name: "_private"},
+		{name: "foo1"},
+		{name: "CIRCLE_TOKEN", warning: true},
+		{name: "circle_branch", warning: true},
+		{name: "1FOO", invalid: true},
+		{name: "X-FOO", invalid: true},
+		{name: "X FOO", invalid: true},
+		{name: "", invalid: true},
+		{name: strings.Repeat("A", maxEnvVarNameLength+1), invalid: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			warning, err := checkEnvVarName(tc.name)
+
+			if (err != nil) != tc.invalid {
+				t.Errorf("Error was incorrect, got: %v, want an error: %t.", err, tc.invalid)
+			}
+
+			if (warning != "") != tc.warning {
+				t.Errorf("Warning was incorrect, got: %q, want a warning: %t.", warning, tc.warning)
+			}
+		})
+	}
+}
+
+func TestValidateEnvVarMap(t *testing.T) {
+	ws, errs := validateEnvVarMap(map[string]interface{}{
+		"X_FOO":        "bar",
+		"CIRCLE_TOKEN": "secret",
+		"1FOO":         "bar",
+		"EMPTY":        "",
+		"LARGE":        strings.Repeat("a", maxEnvVarValueLength+1),
+		"UNKNOWN":      unknownValue,
+	}, "variables")
+
+	if len(ws) != 1 {
+		t.Errorf("Expected one warning, got: %v.", ws)
+	}
+
+	if len(errs) != 3 {
+		t.Errorf("Expected three errors, got: %v.", errs)
+	}
+
+	for _, err := range errs {
+		if strings.Contains(err.Error(), "secret") || strings.Contains(err.Error(), "aaaa") {
+			t.Errorf("Error quotes a value: %s", err)
+		}
+	}
+}
+
+func TestCheckProjectIdentifiers(t *testing.T) {
+	cases := []struct {
+		vcsType string
+		account string
+		project string
+		invalid bool
+	}{
+		{vcsType: "github", account: "my-org", project: "my.repo_1"},
+		{vcsType: "github", account: "-org", project: "repo", invalid: true},
+		{vcsType: "github", account: "my_org", project: "repo", invalid: true},
+		{vcsType: "github", account: "org", project: "..", invalid: true},
+		{vcsType: "github", account: "org", project: "my/repo", invalid: true},
+		{vcsType: "bitbucket", account: "my_workspace", project: "repo"},
+		{vcsType: "bitbucket", account: "my workspace", project: "repo", invalid: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.vcsType+"/"+tc.account+"/"+tc.project, func(t *testing.T) {
+			if err := checkProjectIdentifiers(tc.vcsType, tc.account, tc.project); (err != nil) != tc.invalid {
+				t.Errorf("Error was incorrect, got: %v, want an error: %t.", err, tc.invalid)
+			}
+		})
+	}
+}